@@ -0,0 +1,156 @@
+package prom_mux
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// connStamp holds the time the request currently being read from a
+// connection started arriving, in nanoseconds since the Unix epoch.
+type connStamp struct {
+	// Accessed atomically.
+	nanos int64
+	// Only accessed by ConnTimer.ConnState.
+	idle bool
+}
+
+func (s *connStamp) set(t time.Time) {
+	atomic.StoreInt64(&s.nanos, t.UnixNano())
+}
+
+func (s *connStamp) get() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.nanos))
+}
+
+// ConnTimer stamps the time connections are accepted, and later requests
+// on kept-alive connections start arriving, so that WithAcceptLatency can
+// tell how long a request waited before reaching the handler: in the
+// listen queue, on the TLS handshake and while its headers were read.
+//
+// Hook it into a server with Install, or by setting the ConnContext and
+// ConnState fields of http.Server to its methods of the same names.
+type ConnTimer struct {
+	now func() time.Time
+
+	mtx   sync.Mutex
+	conns map[net.Conn]*connStamp
+}
+
+// NewConnTimer returns a ConnTimer taking the time with now, or with
+// time.Now if now is nil.
+func NewConnTimer(now func() time.Time) *ConnTimer {
+	if now == nil {
+		now = time.Now
+	}
+	return &ConnTimer{now: now, conns: make(map[net.Conn]*connStamp)}
+}
+
+// Install sets the ConnContext and ConnState hooks of srv to those of t,
+// calling the hooks srv had before as well.
+func (t *ConnTimer) Install(srv *http.Server) {
+	connContext, connState := srv.ConnContext, srv.ConnState
+	srv.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		return t.ConnContext(ctx, c)
+	}
+	srv.ConnState = func(c net.Conn, state http.ConnState) {
+		t.ConnState(c, state)
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+}
+
+// ConnContext is meant for http.Server.ConnContext. It stamps the accept
+// time of c and returns a copy of ctx from which requests read on c find
+// it.
+func (t *ConnTimer) ConnContext(ctx context.Context, c net.Conn) context.Context {
+	s := &connStamp{}
+	s.set(t.now())
+	t.mtx.Lock()
+	t.conns[c] = s
+	t.mtx.Unlock()
+	return context.WithValue(ctx, acceptTimeKey, s)
+}
+
+// ConnState is meant for http.Server.ConnState. When a kept-alive
+// connection becomes active again, it stamps the time as the start of the
+// next request. It forgets about connections once they are closed or
+// hijacked.
+func (t *ConnTimer) ConnState(c net.Conn, state http.ConnState) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	s, ok := t.conns[c]
+	if !ok {
+		return
+	}
+	switch state {
+	case http.StateActive:
+		// The first request on a connection is measured from the
+		// accept, the following ones from when they started arriving.
+		if s.idle {
+			s.set(t.now())
+		}
+	case http.StateIdle:
+		s.idle = true
+	case http.StateClosed, http.StateHijacked:
+		delete(t.conns, c)
+	}
+}
+
+// ContextWithAcceptTime returns a copy of ctx carrying t as the time the
+// request started arriving, as if stamped by a ConnTimer. It is meant for
+// servers accepting connections by other means, and for tests.
+func ContextWithAcceptTime(ctx context.Context, t time.Time) context.Context {
+	s := &connStamp{}
+	s.set(t)
+	return context.WithValue(ctx, acceptTimeKey, s)
+}
+
+// AcceptTimeFromContext returns the time the request started arriving, as
+// stored in ctx by a ConnTimer or ContextWithAcceptTime, if any.
+func AcceptTimeFromContext(ctx context.Context) (time.Time, bool) {
+	s, ok := ctx.Value(acceptTimeKey).(*connStamp)
+	if !ok {
+		return time.Time{}, false
+	}
+	return s.get(), true
+}
+
+// WithAcceptLatency makes InstrumentHandlerDuration observe with obs, in
+// seconds, the time from the moment the request started arriving, as
+// stamped by a ConnTimer, until it entered the instrument. obs is
+// partitioned like the observer of InstrumentHandlerDuration. Requests
+// without an accept time in their context are not observed.
+//
+// With WithCumulativeDuration, the latency is measured up to the entry of
+// the outermost such instrument instead. For HTTP/2, where requests are
+// multiplexed on one connection, only the first request of a connection
+// gets a meaningful value; the others are measured from the accept, or
+// from when the connection last went from idle to active.
+func WithAcceptLatency(obs prometheus.ObserverVec) Option {
+	return func(o *options) {
+		o.acceptObserver = obs
+	}
+}
+
+func (o *options) observeAcceptLatency(
+	r *http.Request, labels prometheus.Labels, start time.Time,
+) {
+	if o.acceptObserver == nil {
+		return
+	}
+	accepted, ok := AcceptTimeFromContext(r.Context())
+	if !ok {
+		return
+	}
+	o.acceptObserver.With(labels).Observe(start.Sub(accepted).Seconds())
+}
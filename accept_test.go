@@ -0,0 +1,86 @@
+package prom_mux
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithAcceptLatency(t *testing.T) {
+	const ms = time.Millisecond
+	for _, tc := range []struct {
+		name string
+		// ctx simulates the server up to the request reaching the
+		// instrument.
+		ctx   func(clock *prommuxtest.Clock, timer *ConnTimer, c net.Conn) context.Context
+		count uint64
+		sum   float64
+	}{
+		{"first request", func(clock *prommuxtest.Clock, timer *ConnTimer, c net.Conn) context.Context {
+			ctx := timer.ConnContext(context.Background(), c)
+			clock.Advance(30 * ms) // TLS handshake
+			timer.ConnState(c, http.StateActive)
+			clock.Advance(20 * ms) // reading the headers
+			return ctx
+		}, 1, 0.05},
+		{"kept-alive request", func(clock *prommuxtest.Clock, timer *ConnTimer, c net.Conn) context.Context {
+			ctx := timer.ConnContext(context.Background(), c)
+			timer.ConnState(c, http.StateActive)
+			timer.ConnState(c, http.StateIdle)
+			clock.Advance(time.Minute) // idle time doesn't count
+			timer.ConnState(c, http.StateActive)
+			clock.Advance(10 * ms)
+			return ctx
+		}, 1, 0.01},
+		{"stamped by hand", func(clock *prommuxtest.Clock, _ *ConnTimer, _ net.Conn) context.Context {
+			ctx := ContextWithAcceptTime(context.Background(), clock.Now())
+			clock.Advance(5 * ms)
+			return ctx
+		}, 1, 0.005},
+		{"not stamped", func(clock *prommuxtest.Clock, _ *ConnTimer, _ net.Conn) context.Context {
+			clock.Advance(5 * ms)
+			return context.Background()
+		}, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			timer := NewConnTimer(clock.Now)
+			c, peer := net.Pipe()
+			defer c.Close()
+			defer peer.Close()
+
+			obs, accept := newDurationVec(), newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				// Time spent in the handler doesn't count.
+				clock.Advance(time.Second)
+			}), WithAcceptLatency(accept), WithClock(clock.Now))
+			r := httptest.NewRequest("GET", "/", nil)
+			h.ServeHTTP(httptest.NewRecorder(), r.WithContext(tc.ctx(clock, timer, c)))
+
+			count, sum := prommuxtest.CollectObservation(t, accept, prometheus.Labels{"code": "200"})
+			if count != tc.count || !approx(sum, tc.sum) {
+				t.Errorf("got %d observations summing to %v, want %d of %v", count, sum, tc.count, tc.sum)
+			}
+		})
+	}
+}
+
+func TestConnTimerForgetsClosedConns(t *testing.T) {
+	timer := NewConnTimer(nil)
+	c, peer := net.Pipe()
+	defer peer.Close()
+	timer.ConnContext(context.Background(), c)
+	timer.ConnState(c, http.StateActive)
+	c.Close()
+	timer.ConnState(c, http.StateClosed)
+	if n := len(timer.conns); n != 0 {
+		t.Errorf("%d connections tracked after closing, want 0", n)
+	}
+}
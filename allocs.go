@@ -0,0 +1,58 @@
+package prom_mux
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// allocsMtx serializes the requests measured by InstrumentHandlerAllocations
+// so they don't see each other's allocations.
+var allocsMtx sync.Mutex
+
+// InstrumentHandlerAllocations is a diagnostic tool for hunting allocation
+// hot spots. For every request for which enabled returns true, it observes
+// with obs the number of bytes allocated on the heap while next was
+// serving it. obs is partitioned like for InstrumentHandlerDuration.
+//
+// Do not leave this enabled in production. runtime.ReadMemStats stops the
+// world twice per measured request, and measured requests are serialized
+// with each other so their allocations can be told apart. Allocations made
+// concurrently by other goroutines, including requests for which enabled
+// returned false, are still counted towards the measured request, so the
+// results are most meaningful on an otherwise idle process. enabled is
+// typically a check for a debug header or a sampling decision.
+func InstrumentHandlerAllocations(
+	obs prometheus.ObserverVec,
+	next http.Handler,
+	enabled func(*http.Request) bool,
+	opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerAllocations", "observer", obs)
+	mustNotBeNil("InstrumentHandlerAllocations", "handler", next)
+	mustNotBeNil("InstrumentHandlerAllocations", "enabled func", enabled)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) || !enabled(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+
+		allocsMtx.Lock()
+		defer allocsMtx.Unlock()
+
+		var before, after runtime.MemStats
+		d := newDelegator(w, nil)
+		runtime.ReadMemStats(&before)
+		next.ServeHTTP(d, r)
+		runtime.ReadMemStats(&after)
+
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), float64(after.TotalAlloc-before.TotalAlloc), r, d, o.now().Sub(now))
+		releaseLabels(labels)
+	}
+}
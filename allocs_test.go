@@ -0,0 +1,43 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// allocSink keeps allocations made by test handlers from being optimized
+// away.
+var allocSink []byte
+
+func TestInstrumentHandlerAllocations(t *testing.T) {
+	const size = 1 << 20
+	for _, tc := range []struct {
+		name    string
+		enabled bool
+		want    uint64
+	}{
+		{"enabled", true, 1},
+		{"disabled", false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerAllocations(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				allocSink = make([]byte, size)
+			}), func(*http.Request) bool { return tc.enabled })
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"})
+			if count != tc.want {
+				t.Fatalf("got %d observations, want %d", count, tc.want)
+			}
+			if tc.enabled && sum < size {
+				t.Errorf("observed %v bytes allocated, want at least %d", sum, size)
+			}
+		})
+	}
+}
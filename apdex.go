@@ -0,0 +1,175 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	apdexSlots            = 10
+	defaultApdexWindow    = 5 * time.Minute
+	defaultApdexThreshold = 500 * time.Millisecond
+)
+
+// ApdexOpts configures an Apdex collector.
+type ApdexOpts struct {
+	Namespace   string
+	Subsystem   string
+	Name        string
+	Help        string
+	ConstLabels prometheus.Labels
+
+	// Threshold is the target duration T. Requests served within T are
+	// satisfied, within 4T tolerating and slower ones frustrated.
+	// Defaults to 500ms.
+	Threshold time.Duration
+	// RouteThresholds overrides Threshold for the given values of the
+	// "path" label.
+	RouteThresholds map[string]time.Duration
+	// Window is the period the score is computed over. Defaults to five
+	// minutes. It can't be shorter than 10ns, one per slot.
+	Window time.Duration
+}
+
+// Apdex is a collector exposing, per "path" label, the Apdex score of the
+// requests instrumented with InstrumentHandlerApdex:
+//
+//	(satisfied + tolerating/2) / total
+//
+// The score is computed in-process over a rolling window made of ten
+// slots, so it moves in steps of a tenth of the window and only reflects
+// the requests served by this process. Paths without requests in the
+// window are not exported. Prefer computing Apdex from histogram buckets
+// in PromQL where possible; this is for setups where that's impractical.
+type Apdex struct {
+	desc      *prometheus.Desc
+	threshold time.Duration
+	routes    map[string]time.Duration
+	slot      time.Duration
+
+	mtx    sync.Mutex
+	scores map[string]*apdexScore
+}
+
+type apdexSlot struct {
+	epoch                             int64
+	satisfied, tolerating, frustrated uint64
+}
+
+type apdexScore struct {
+	slots [apdexSlots]apdexSlot
+}
+
+// NewApdex creates an Apdex collector. It has to be registered to be
+// exported. It panics if opts.Window is positive but too short.
+func NewApdex(opts ApdexOpts) *Apdex {
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultApdexThreshold
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultApdexWindow
+	}
+	if opts.Window < apdexSlots {
+		panic(fmt.Sprintf("NewApdex: Window %v is shorter than %dns", opts.Window, apdexSlots))
+	}
+	if opts.Help == "" {
+		opts.Help = "Apdex score of HTTP requests over a rolling window."
+	}
+	return &Apdex{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			opts.Help, []string{"path"}, opts.ConstLabels,
+		),
+		threshold: opts.Threshold,
+		routes:    opts.RouteThresholds,
+		slot:      opts.Window / apdexSlots,
+		scores:    make(map[string]*apdexScore),
+	}
+}
+
+func (a *Apdex) observe(path string, d time.Duration, now time.Time) {
+	t, ok := a.routes[path]
+	if !ok {
+		t = a.threshold
+	}
+	epoch := now.UnixNano() / int64(a.slot)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	score, ok := a.scores[path]
+	if !ok {
+		score = &apdexScore{}
+		a.scores[path] = score
+	}
+	s := &score.slots[epoch%apdexSlots]
+	if s.epoch != epoch {
+		*s = apdexSlot{epoch: epoch}
+	}
+	switch {
+	case d <= t:
+		s.satisfied++
+	case d <= 4*t:
+		s.tolerating++
+	default:
+		s.frustrated++
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (a *Apdex) Describe(ch chan<- *prometheus.Desc) {
+	ch <- a.desc
+}
+
+// Collect implements prometheus.Collector.
+func (a *Apdex) Collect(ch chan<- prometheus.Metric) {
+	epoch := time.Now().UnixNano() / int64(a.slot)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	for path, score := range a.scores {
+		var satisfied, tolerating, total uint64
+		for _, s := range score.slots {
+			if s.epoch <= epoch-apdexSlots {
+				continue
+			}
+			satisfied += s.satisfied
+			tolerating += s.tolerating
+			total += s.satisfied + s.tolerating + s.frustrated
+		}
+		if total == 0 {
+			delete(a.scores, path)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			a.desc, prometheus.GaugeValue,
+			(float64(satisfied)+float64(tolerating)/2)/float64(total),
+			path,
+		)
+	}
+}
+
+// InstrumentHandlerApdex feeds the duration of every request served by next
+// into a, keyed by the "path" label.
+func InstrumentHandlerApdex(
+	a *Apdex, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerApdex", "Apdex", a)
+	mustNotBeNil("InstrumentHandlerApdex", "handler", next)
+	o := newOptions(opts)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		next.ServeHTTP(w, r)
+		elapsed := o.now().Sub(now)
+		// The rolling window always follows the wall clock, as that's
+		// what Collect looks at.
+		a.observe(o.path(r), elapsed, time.Now())
+	}
+}
@@ -0,0 +1,55 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerApdex(t *testing.T) {
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	a := NewApdex(ApdexOpts{Name: "test_apdex", Threshold: 500 * time.Millisecond})
+	h := InstrumentHandlerApdex(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := time.ParseDuration(r.URL.Query().Get("d"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(d)
+	}), WithClock(clock.Now))
+
+	// One satisfied, one tolerating and one frustrated request.
+	for _, d := range []string{"100ms", "1s", "3s"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?d="+d, nil))
+	}
+	_, score := prommuxtest.CollectObservation(t, a, prometheus.Labels{"path": defaultUnmatchedPath})
+	if want := (1 + 0.5) / 3; score != want {
+		t.Errorf("score is %v, want %v", score, want)
+	}
+}
+
+func TestNewApdexWindow(t *testing.T) {
+	for _, tc := range []struct {
+		window time.Duration
+		panics bool
+	}{
+		{0, false},
+		{-time.Second, false},
+		{apdexSlots, false},
+		{1, true},
+		{apdexSlots - 1, true},
+	} {
+		func() {
+			defer func() {
+				if panicked := recover() != nil; panicked != tc.panics {
+					t.Errorf("NewApdex with a %v window panicked: %v, want %v", tc.window, panicked, tc.panics)
+				}
+			}()
+			NewApdex(ApdexOpts{Name: "test_apdex", Window: tc.window})
+		}()
+	}
+}
@@ -0,0 +1,28 @@
+package prom_mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerArrivalPhase observes with obs, for every request
+// reaching next, the fraction of the wall-clock second at which it
+// arrived, from 0 (inclusive) to 1 (exclusive). With uniformly spread
+// traffic the buckets fill evenly; spikes reveal clients synchronized to
+// the clock, like cron jobs or retries on whole-second timers, which cause
+// thundering herds. prometheus.LinearBuckets(0.05, 0.05, 19) is a
+// reasonable bucket layout.
+//
+// This is a diagnostic instrument, it is not useful on most services.
+func InstrumentHandlerArrivalPhase(
+	obs prometheus.Observer, next http.Handler,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerArrivalPhase", "observer", obs)
+	mustNotBeNil("InstrumentHandlerArrivalPhase", "handler", next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		obs.Observe(float64(time.Now().Nanosecond()) / float64(time.Second))
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,36 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerArrivalPhase(t *testing.T) {
+	obs := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test_request_arrival_phase",
+		Help:    "Test arrival phases.",
+		Buckets: prometheus.LinearBuckets(0.05, 0.05, 19),
+	})
+	var phases []float64
+	h := InstrumentHandlerArrivalPhase(prometheus.ObserverFunc(func(v float64) {
+		phases = append(phases, v)
+		obs.Observe(v)
+	}), http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	for _, p := range phases {
+		if p < 0 || p >= 1 {
+			t.Errorf("observed phase %v, want it in [0, 1)", p)
+		}
+	}
+	if count, _ := prommuxtest.CollectObservation(t, obs, nil); count != 10 {
+		t.Errorf("got %d observations, want 10", count)
+	}
+}
@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"math"
+	"sort"
+)
+
+// FastBuckets are histogram buckets for durations in seconds of requests
+// served in well under a millisecond, like internal RPCs answered from
+// memory, ranging from 10µs to 25ms. prometheus.DefBuckets start at 5ms,
+// which puts all such requests in the first bucket.
+var FastBuckets = []float64{
+	.00001, .000025, .00005, .0001, .00025, .0005, .001, .0025, .005, .01, .025,
+}
+
+// WebBuckets are histogram buckets for durations in seconds of requests
+// served over the network, ranging from 1ms for cached responses to 30s for
+// slow uploads and long polls. They are the default of
+// NewRequestDurationHistogram.
+var WebBuckets = []float64{
+	.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30,
+}
+
+// sloBucketSpread is how far, as a factor of the target, the outermost
+// buckets returned by SLOCenteredBuckets lie from it.
+const sloBucketSpread = 10
+
+// SLOCenteredBuckets returns count histogram buckets clustered around
+// target, e.g. a latency SLO in seconds. One bucket is exactly target, the
+// others spread out below and above it down to target/10 and up to
+// 10*target, with the distance between neighbouring buckets growing
+// quadratically (in log space) away from target. This gives precise
+// quantile estimations near the SLO with far fewer buckets than an even
+// layout would need.
+//
+// The function panics if count is less than 1 or target is not positive.
+func SLOCenteredBuckets(target float64, count int) []float64 {
+	if count < 1 {
+		panic("SLOCenteredBuckets needs a positive count")
+	}
+	if target <= 0 {
+		panic("SLOCenteredBuckets needs a positive target")
+	}
+
+	below := (count - 1) / 2
+	above := count - 1 - below
+	buckets := make([]float64, 0, count)
+	buckets = append(buckets, target)
+	spread := math.Log(sloBucketSpread)
+	for i := 1; i <= below; i++ {
+		step := spread * float64(i*i) / float64(below*below)
+		buckets = append(buckets, target*math.Exp(-step))
+	}
+	for i := 1; i <= above; i++ {
+		step := spread * float64(i*i) / float64(above*above)
+		buckets = append(buckets, target*math.Exp(step))
+	}
+	sort.Float64s(buckets)
+	return buckets
+}
@@ -0,0 +1,181 @@
+package prom_mux
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestSLOCenteredBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		target          float64
+		count           int
+		lowest, highest float64
+	}{
+		{0.3, 1, 0.3, 0.3},
+		{0.3, 2, 0.3, 3},
+		{0.3, 7, 0.03, 3},
+		{0.25, 12, 0.025, 2.5},
+	} {
+		b := SLOCenteredBuckets(tc.target, tc.count)
+		if len(b) != tc.count {
+			t.Errorf("SLOCenteredBuckets(%v, %d) returned %d buckets", tc.target, tc.count, len(b))
+			continue
+		}
+		at := -1
+		for i, v := range b {
+			if i > 0 && v <= b[i-1] {
+				t.Errorf("SLOCenteredBuckets(%v, %d) = %v is not strictly increasing", tc.target, tc.count, b)
+			}
+			if v == tc.target {
+				at = i
+			}
+		}
+		if at < 0 {
+			t.Errorf("SLOCenteredBuckets(%v, %d) = %v doesn't contain the target", tc.target, tc.count, b)
+			continue
+		}
+		if !near(b[0], tc.lowest) || !near(b[len(b)-1], tc.highest) {
+			t.Errorf("SLOCenteredBuckets(%v, %d) = %v, want buckets from %v to %v",
+				tc.target, tc.count, b, tc.lowest, tc.highest)
+		}
+		// Buckets are densest around the target.
+		for i := at + 1; i+1 < len(b); i++ {
+			if b[i+1]/b[i] <= b[i]/b[i-1] {
+				t.Errorf("SLOCenteredBuckets(%v, %d) = %v: ratios don't grow above the target", tc.target, tc.count, b)
+			}
+		}
+		for i := at - 1; i > 0; i-- {
+			if b[i]/b[i-1] <= b[i+1]/b[i] {
+				t.Errorf("SLOCenteredBuckets(%v, %d) = %v: ratios don't grow below the target", tc.target, tc.count, b)
+			}
+		}
+	}
+}
+
+func TestSLOCenteredBucketsObservations(t *testing.T) {
+	const target = 0.3
+	reg := prometheus.NewRegistry()
+	obs, err := NewRequestDurationHistogram(reg, prometheus.HistogramOpts{
+		Buckets: SLOCenteredBuckets(target, 31),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		d, _ := time.ParseDuration(r.URL.Query().Get("d"))
+		clock.Advance(d)
+	}), WithClock(clock.Now))
+	for _, d := range []string{"290ms", "300ms", "310ms", "5s"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?d="+d, nil))
+	}
+	if count, sum := prommuxtest.CollectObservation(t, obs, nil); count != 4 || !near(sum, 5.9) {
+		t.Errorf("got %d observations summing to %v, want 4 summing to 5.9", count, sum)
+	}
+
+	// With 31 buckets, the ones next to the SLO are about 1% away, so
+	// requests missing it by 10ms fall on the right side.
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	buckets := mfs[0].GetMetric()[0].GetHistogram().GetBucket()
+	for i, b := range buckets {
+		if b.GetUpperBound() != target {
+			continue
+		}
+		got := []uint64{buckets[i-1].GetCumulativeCount(), b.GetCumulativeCount(), buckets[i+1].GetCumulativeCount()}
+		if got[0] != 1 || got[1] != 2 || got[2] != 2 {
+			t.Errorf("got %v requests up to the buckets around %v, want [1 2 2]", got, target)
+		}
+		return
+	}
+	t.Errorf("no bucket at %v", target)
+}
+
+func TestSLOCenteredBucketsPanics(t *testing.T) {
+	for _, tc := range []struct {
+		target float64
+		count  int
+	}{
+		{0.3, 0},
+		{0, 5},
+		{-1, 5},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("SLOCenteredBuckets(%v, %d) did not panic", tc.target, tc.count)
+				}
+			}()
+			SLOCenteredBuckets(tc.target, tc.count)
+		}()
+	}
+}
+
+// near reports whether a and b are equal but for floating point errors.
+func near(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func TestPresetBuckets(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		buckets []float64 // nil for the default
+		want    []float64
+		took    time.Duration
+		bucket  float64 // the lowest one the request falls into
+	}{
+		{"FastBuckets", FastBuckets, FastBuckets, 30 * time.Microsecond, .00005},
+		{"WebBuckets", WebBuckets, WebBuckets, 120 * time.Millisecond, .25},
+		{"default", nil, WebBuckets, 3 * time.Second, 5},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 1; i < len(tc.want); i++ {
+				if tc.want[i] <= tc.want[i-1] {
+					t.Fatalf("buckets %v not increasing at %d", tc.want, i)
+				}
+			}
+
+			reg := prometheus.NewRegistry()
+			obs, err := NewRequestDurationHistogram(reg, prometheus.HistogramOpts{Buckets: tc.buckets})
+			if err != nil {
+				t.Fatal(err)
+			}
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				clock.Advance(tc.took)
+			}), WithClock(clock.Now))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			if count, sum := prommuxtest.CollectObservation(t, obs, nil); count != 1 || !near(sum, tc.took.Seconds()) {
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, tc.took.Seconds())
+			}
+			mfs, err := reg.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			buckets := mfs[0].GetMetric()[0].GetHistogram().GetBucket()
+			if len(buckets) != len(tc.want) {
+				t.Fatalf("got %d buckets, want %d", len(buckets), len(tc.want))
+			}
+			for i, b := range buckets {
+				want := uint64(0)
+				if b.GetUpperBound() >= tc.bucket {
+					want = 1
+				}
+				if b.GetUpperBound() != tc.want[i] || b.GetCumulativeCount() != want {
+					t.Errorf("bucket %d is le=%v with %d, want le=%v with %d",
+						i, b.GetUpperBound(), b.GetCumulativeCount(), tc.want[i], want)
+				}
+			}
+		})
+	}
+}
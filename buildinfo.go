@@ -0,0 +1,35 @@
+package prom_mux
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// WithBuildInfoLabel sets the "version" label of every observation to
+// version, typically the version of the running build, so that metrics can
+// be compared before and after a deploy and joined with a build_info
+// metric on the same label. The label is curried into the vectors when the
+// instrument is created, which panics if a vector isn't partitioned by
+// "version".
+func WithBuildInfoLabel(version string) Option {
+	return func(o *options) {
+		o.addConstLabel("version", version)
+	}
+}
+
+// WithConstLabels sets the labels in labels to the same value for every
+// observation, e.g. a "service" label for a handler running behind several
+// logical services. Like with WithBuildInfoLabel, the labels are curried
+// into the vectors once when the instrument is created, which panics if a
+// vector isn't partitioned by all of them.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		for name, value := range labels {
+			o.addConstLabel(name, value)
+		}
+	}
+}
+
+func (o *options) addConstLabel(name, value string) {
+	if o.constLabels == nil {
+		o.constLabels = prometheus.Labels{}
+	}
+	o.constLabels[name] = value
+}
@@ -0,0 +1,45 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithBuildInfoLabel(t *testing.T) {
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, tc := range []struct {
+		name string
+		new  func(opts ...Option) (prometheus.Collector, http.Handler)
+	}{
+		{"duration", func(opts ...Option) (prometheus.Collector, http.Handler) {
+			obs := newDurationVec("version")
+			return obs, InstrumentHandlerDuration(obs, noop, opts...)
+		}},
+		{"counter", func(opts ...Option) (prometheus.Collector, http.Handler) {
+			cnt := newCounterVec("version")
+			return cnt, InstrumentHandlerCounter(cnt, noop, opts...)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, h := tc.new(WithBuildInfoLabel("v1.2.3"))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if count, _ := prommuxtest.CollectObservation(t, c, prometheus.Labels{"version": "v1.2.3"}); count != 1 {
+				t.Errorf("got %d observations for version v1.2.3, want 1", count)
+			}
+		})
+	}
+}
+
+func TestWithBuildInfoLabelMissing(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("vector without a version label did not panic")
+		}
+	}()
+	InstrumentHandlerDuration(newDurationVec(), http.NotFoundHandler(), WithBuildInfoLabel("v1.2.3"))
+}
@@ -0,0 +1,92 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheOutcome tells how a request was served with respect to a cache in
+// front of an upstream. See ReportCacheOutcome.
+type CacheOutcome int32
+
+// Cache outcomes, from cheapest to most expensive. They are used as values
+// of the "cache" label by InstrumentHandlerCacheOutcomes.
+const (
+	// CacheHit means the response was served from the cache without
+	// contacting the upstream.
+	CacheHit CacheOutcome = iota + 1
+	// CacheRevalidated means a cached response was served after the
+	// upstream confirmed it was still fresh, e.g. with a 304.
+	CacheRevalidated
+	// CacheMiss means the response had to be fetched from the upstream.
+	CacheMiss
+)
+
+func (c CacheOutcome) String() string {
+	switch c {
+	case CacheHit:
+		return "hit"
+	case CacheRevalidated:
+		return "revalidated"
+	case CacheMiss:
+		return "miss"
+	default:
+		return otherLabelValue
+	}
+}
+
+type cacheOutcomeRecorder struct {
+	// Accessed atomically; 0 until reported.
+	outcome int32
+}
+
+// InstrumentHandlerCacheOutcomes counts with counter the requests for which
+// the handlers wrapped by next reported a CacheOutcome with
+// ReportCacheOutcome. counter must be partitioned like the observer of
+// InstrumentHandlerDuration plus a "cache" label holding "hit",
+// "revalidated" or "miss". Requests for which nothing was reported are not
+// counted.
+//
+// Telling revalidated hits apart from plain ones shows how much upstream
+// traffic a cache still causes although its hit ratio looks fine.
+func InstrumentHandlerCacheOutcomes(
+	counter *prometheus.CounterVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerCacheOutcomes", "counter", counter)
+	mustNotBeNil("InstrumentHandlerCacheOutcomes", "handler", next)
+	o := newOptions(opts)
+	counter = o.curryCounter(counter, "cache")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		rec := &cacheOutcomeRecorder{}
+		r = r.WithContext(context.WithValue(r.Context(), cacheOutcomeKey, rec))
+		d := newDelegator(w, nil)
+		next.ServeHTTP(d, r)
+
+		outcome := CacheOutcome(atomic.LoadInt32(&rec.outcome))
+		if outcome == 0 {
+			return
+		}
+		labels := o.labels(r, d)
+		labels["cache"] = outcome.String()
+		o.add(counter.With(labels), 1, r, d, o.now().Sub(now))
+		releaseLabels(labels)
+	}
+}
+
+// ReportCacheOutcome records how the request ctx belongs to was served. If
+// it is called more than once, the last outcome wins. It does nothing if
+// the request is not instrumented with InstrumentHandlerCacheOutcomes, and
+// is safe for concurrent use.
+func ReportCacheOutcome(ctx context.Context, outcome CacheOutcome) {
+	if rec, ok := ctx.Value(cacheOutcomeKey).(*cacheOutcomeRecorder); ok {
+		atomic.StoreInt32(&rec.outcome, int32(outcome))
+	}
+}
@@ -0,0 +1,49 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerCacheOutcomes(t *testing.T) {
+	cnt := newCounterVec("cache")
+	h := InstrumentHandlerCacheOutcomes(cnt, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("cache") {
+		case "hit":
+			ReportCacheOutcome(r.Context(), CacheHit)
+		case "revalidated":
+			// The last outcome wins.
+			ReportCacheOutcome(r.Context(), CacheMiss)
+			ReportCacheOutcome(r.Context(), CacheRevalidated)
+		case "miss":
+			ReportCacheOutcome(r.Context(), CacheMiss)
+		}
+	}))
+	for _, target := range []string{
+		"/?cache=hit", "/?cache=hit", "/?cache=hit",
+		"/?cache=revalidated", "/?cache=revalidated",
+		"/?cache=miss",
+		"/",
+	} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	for _, tc := range []struct {
+		cache string
+		want  uint64
+	}{
+		{"hit", 3},
+		{"revalidated", 2},
+		{"miss", 1},
+		{otherLabelValue, 0},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, cnt, prometheus.Labels{"cache": tc.cache}); count != tc.want {
+			t.Errorf("got %d requests with cache %q, want %d", count, tc.cache, tc.want)
+		}
+	}
+}
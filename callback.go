@@ -0,0 +1,40 @@
+package prom_mux
+
+import (
+	"net/http"
+	"time"
+)
+
+// InstrumentHandlerFunc calls observe after next has served a request with
+// the response status (200 if the handler didn't set one, as net/http
+// sends), the method and path as they would be labeled by the other
+// instruments, the duration and the number of response body bytes written.
+// It is meant for feeding metrics backends other than Prometheus with the
+// same bookkeeping; the Prometheus instruments do not use it.
+//
+// The options selecting or rewriting the method and path, WithSkipPaths,
+// WithClock and WithTimeoutHandlerStatus apply. Options adding labels have
+// no effect, and neither have the ones taking Prometheus instruments.
+func InstrumentHandlerFunc(
+	next http.Handler,
+	observe func(code int, method, path string, dur time.Duration, written int64),
+	opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerFunc", "handler", next)
+	mustNotBeNil("InstrumentHandlerFunc", "observe", observe)
+	o := newOptions(opts)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		elapsed := o.now().Sub(now)
+		code := effectiveCode(o.status(r, d))
+		observe(code, o.method(r), o.pathLabelValue(r), elapsed, d.Written())
+		releaseDelegator(rwd)
+	}
+}
@@ -0,0 +1,55 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerFunc(t *testing.T) {
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		code    int
+		written int64
+	}{
+		{"explicit", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		}, http.StatusNotFound, 9},
+		{"implicit", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hi"))
+		}, http.StatusOK, 2},
+		{"nothing", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			called := 0
+			h := InstrumentHandlerFunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(time.Second)
+				tc.handler(w, r)
+			}), func(code int, method, path string, dur time.Duration, written int64) {
+				called++
+				if code != tc.code {
+					t.Errorf("code = %d, want %d", code, tc.code)
+				}
+				if method != "post" || path != defaultUnmatchedPath {
+					t.Errorf("method, path = %q, %q, want \"post\", %q", method, path, defaultUnmatchedPath)
+				}
+				if dur != time.Second {
+					t.Errorf("dur = %v, want 1s", dur)
+				}
+				if written != tc.written {
+					t.Errorf("written = %d, want %d", written, tc.written)
+				}
+			}, WithClock(clock.Now))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+			if called != 1 {
+				t.Errorf("observe called %d times, want 1", called)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package prom_mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// WithContextCancelLabel adds a "cancelled" label, "true" if the request
+// context was canceled by the time the handler returned, typically because
+// the client went away, and "false" otherwise. It tells impatient clients
+// apart from slow responses, which look the same in the duration alone.
+// Deadlines exceeded are not counted as cancellations.
+func WithContextCancelLabel() Option {
+	return func(o *options) {
+		o.addLabel("cancelled", func(r *http.Request, _ Delegator) string {
+			if errors.Is(r.Context().Err(), context.Canceled) {
+				return "true"
+			}
+			return "false"
+		})
+	}
+}
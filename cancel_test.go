@@ -0,0 +1,45 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithContextCancelLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		ctx  func() (context.Context, context.CancelFunc)
+		want string
+	}{
+		{"served", func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		}, "false"},
+		{"canceled", func() (context.Context, context.CancelFunc) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx, cancel
+		}, "true"},
+		{"deadline exceeded", func() (context.Context, context.CancelFunc) {
+			return context.WithDeadline(context.Background(), time.Unix(0, 0))
+		}, "false"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("cancelled")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+				WithContextCancelLabel())
+			ctx, cancel := tc.ctx()
+			defer cancel()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"cancelled": tc.want}); count != 1 {
+				t.Errorf("got %d observations with cancelled %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package prom_mux
+
+import "regexp"
+
+// catchAllRe matches path templates ending in a variable that matches any
+// remainder of the path, like "/{rest:.*}" or "/static/{file:.+}".
+var catchAllRe = regexp.MustCompile(`\{[^{}:]+:\.[*+]\}$`)
+
+// WithCatchAllPath replaces the "path" label of routes whose path template
+// ends in a catch-all variable, like "/static/{rest:.*}", with label (e.g.
+// "/static/*"). Routes that are not catch-all keep their template.
+func WithCatchAllPath(label string) Option {
+	return func(o *options) {
+		o.addPathRewrite(func(path string) string {
+			if catchAllRe.MatchString(path) {
+				return label
+			}
+			return path
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithCatchAllPath(t *testing.T) {
+	obs := newDurationVec()
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithCatchAllPath("/static/*")))
+	noop := func(http.ResponseWriter, *http.Request) {}
+	router.HandleFunc("/users/{id}", noop)
+	router.HandleFunc("/files/{name:[a-z]+}", noop)
+	router.HandleFunc("/static/{rest:.*}", noop)
+
+	for _, target := range []string{"/static/a.css", "/static/js/b.js", "/users/1", "/files/x"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	for _, tc := range []struct {
+		path string
+		want uint64
+	}{
+		{"/static/*", 2},
+		{"/users/{id}", 1},
+		{"/files/{name:[a-z]+}", 1},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.path}); count != tc.want {
+			t.Errorf("got %d observations with path %q, want %d", count, tc.path, tc.want)
+		}
+	}
+}
+
+func TestCatchAllRe(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		want     bool
+	}{
+		{"/{rest:.*}", true},
+		{"/static/{file:.+}", true},
+		{"/users/{id}", false},
+		{"/files/{name:[a-z]+}", false},
+		{"/{rest:.*}/edit", false},
+	} {
+		if got := catchAllRe.MatchString(tc.template); got != tc.want {
+			t.Errorf("%q is catch-all: %v, want %v", tc.template, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package prom_mux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ClaimLabel maps a JWT claim to a label. Only the listed Values are used
+// as label values; any other value is labeled "other", and a missing claim
+// "none".
+type ClaimLabel struct {
+	Claim  string
+	Label  string
+	Values []string
+}
+
+// WithClaimLabels adds a label for each of the given mappings, taking its
+// value from the claims returned by claims for the request context.
+// claims is the accessor of whatever JWT middleware parsed the token; it
+// may return nil for unauthenticated requests. Non-string claim values are
+// formatted with fmt.Sprint before being checked against the allowed
+// values.
+func WithClaimLabels(
+	claims func(context.Context) map[string]interface{}, mappings ...ClaimLabel,
+) Option {
+	return func(o *options) {
+		for _, m := range mappings {
+			claim, allowed := m.Claim, newStringSet(m.Values)
+			o.addLabel(m.Label, func(r *http.Request, _ Delegator) string {
+				v, ok := claims(r.Context())[claim]
+				if !ok {
+					return noneLabelValue
+				}
+				if s, ok := v.(string); ok {
+					return allowed.get(s)
+				}
+				return allowed.get(fmt.Sprint(v))
+			})
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+type claimsKey struct{}
+
+func TestWithClaimLabels(t *testing.T) {
+	obs := newDurationVec("plan", "admin")
+	instrumented := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithClaimLabels(func(ctx context.Context) map[string]interface{} {
+			claims, _ := ctx.Value(claimsKey{}).(map[string]interface{})
+			return claims
+		},
+			ClaimLabel{Claim: "plan", Label: "plan", Values: []string{"free", "pro"}},
+			ClaimLabel{Claim: "adm", Label: "admin", Values: []string{"true", "false"}},
+		))
+
+	for _, tc := range []struct {
+		name   string
+		claims map[string]interface{}
+		labels prometheus.Labels
+	}{
+		{"allowed", map[string]interface{}{"plan": "pro", "adm": false},
+			prometheus.Labels{"plan": "pro", "admin": "false"}},
+		{"not allowed", map[string]interface{}{"plan": "enterprise", "adm": true},
+			prometheus.Labels{"plan": otherLabelValue, "admin": "true"}},
+		{"missing", map[string]interface{}{"plan": "free"},
+			prometheus.Labels{"plan": "free", "admin": noneLabelValue}},
+		{"unauthenticated", nil,
+			prometheus.Labels{"plan": noneLabelValue, "admin": noneLabelValue}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.claims != nil {
+				r = r.WithContext(context.WithValue(r.Context(), claimsKey{}, tc.claims))
+			}
+			instrumented.ServeHTTP(httptest.NewRecorder(), r)
+			if count, _ := prommuxtest.CollectObservation(t, obs, tc.labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, tc.labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package prom_mux
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ContextWithLatencyClass returns a copy of ctx declaring the latency class
+// of the request, e.g. "interactive" or "batch". See WithClassObservers.
+func ContextWithLatencyClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, latencyClassKey, class)
+}
+
+// LatencyClassFromContext returns the latency class stored in ctx, if any.
+func LatencyClassFromContext(ctx context.Context) (string, bool) {
+	class, ok := ctx.Value(latencyClassKey).(string)
+	return class, ok
+}
+
+// WithClassObservers makes InstrumentHandlerDuration observe requests whose
+// context carries a latency class (see ContextWithLatencyClass) with the
+// vector registered for that class in vecs, so each class can use buckets
+// suited to it. Requests without a class, or with a class missing from
+// vecs, are observed with the vector passed to InstrumentHandlerDuration.
+//
+// All vectors must be partitioned by the same labels as the primary one,
+// otherwise InstrumentHandlerDuration panics.
+func WithClassObservers(vecs map[string]prometheus.ObserverVec) Option {
+	return func(o *options) {
+		o.classObservers = vecs
+	}
+}
@@ -0,0 +1,56 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithClassObservers(t *testing.T) {
+	primary := newDurationVec()
+	batch := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "test_batch_request_duration_seconds",
+		Help:    "Test batch request durations.",
+		Buckets: []float64{1, 10, 60},
+	}, []string{"code", "method", "path"})
+	instrumented := InstrumentHandlerDuration(primary, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithClassObservers(map[string]prometheus.ObserverVec{"batch": batch}))
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if class := r.Header.Get("X-Class"); class != "" {
+			r = r.WithContext(ContextWithLatencyClass(r.Context(), class))
+		}
+		instrumented.ServeHTTP(w, r)
+	})
+	for _, class := range []string{"batch", "batch", "interactive", ""} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Class", class)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for _, tc := range []struct {
+		name string
+		vec  prometheus.Collector
+		want uint64
+	}{
+		{"batch", batch, 2},
+		{"primary", primary, 2},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, tc.vec, prometheus.Labels{"code": "200"}); count != tc.want {
+			t.Errorf("%s vector: got %d observations, want %d", tc.name, count, tc.want)
+		}
+	}
+}
+
+func TestWithClassObserversLabels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("class observer with different labels did not panic")
+		}
+	}()
+	InstrumentHandlerDuration(newDurationVec(), http.NotFoundHandler(),
+		WithClassObservers(map[string]prometheus.ObserverVec{"batch": newDurationVec("extra")}))
+}
@@ -0,0 +1,56 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// InstrumentRoundTripperDuration observes with obs the duration of the
+// outbound requests made through next, the client side counterpart of
+// InstrumentHandlerDuration. obs is partitioned by "code" and "method", named
+// and sanitized like for handlers, and by "path" if the path of outbound
+// requests is extracted with WithPathExtractor, typically mapping the URL to
+// the template of the called endpoint. There is no route to take it from.
+//
+// Like with promhttp, requests failing without a response are not observed.
+// Only the options dealing with these labels, WithClock and
+// WithConstLabels apply. Like for handlers, it panics if obs isn't
+// partitioned by the labels it sets.
+func InstrumentRoundTripperDuration(
+	obs prometheus.ObserverVec, next http.RoundTripper, opts ...Option,
+) promhttp.RoundTripperFunc {
+	mustNotBeNil("InstrumentRoundTripperDuration", "observer", obs)
+	mustNotBeNil("InstrumentRoundTripperDuration", "round tripper", next)
+	o := newOptions(opts)
+	names := []string{o.codeLabel, o.methodLabel}
+	if o.pathFunc != nil {
+		names = append(names, o.pathLabel)
+	}
+	o.checkLabelNames(obs, names...)
+	obs = o.curryConst(obs)
+	return func(r *http.Request) (*http.Response, error) {
+		start := o.now()
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+		labels := prometheus.Labels{}
+		if o.codeLabel != "" {
+			if o.statusClass {
+				labels[o.codeLabel] = statusClass(resp.StatusCode)
+			} else {
+				labels[o.codeLabel] = sanitizeCode(resp.StatusCode)
+			}
+		}
+		if o.methodLabel != "" {
+			labels[o.methodLabel] = o.method(r)
+		}
+		if o.pathFunc != nil {
+			labels[o.pathLabel] = o.path(r)
+		}
+		obs.With(labels).Observe(o.now().Sub(start).Seconds())
+		return resp, err
+	}
+}
@@ -0,0 +1,76 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// statusTransport answers every request with an empty response with the
+// status given in the "status" query parameter.
+var statusTransport = promhttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	switch r.URL.Query().Get("status") {
+	case "404":
+		rec.WriteHeader(http.StatusNotFound)
+	default:
+		rec.WriteHeader(http.StatusOK)
+	}
+	return rec.Result(), nil
+})
+
+func TestInstrumentRoundTripperDuration(t *testing.T) {
+	obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_client_request_duration_seconds",
+		Help: "Test client request durations.",
+	}, []string{"code", "method", "path"})
+	rt := InstrumentRoundTripperDuration(obs, statusTransport,
+		WithPathExtractor(func(r *http.Request) string { return "/users/{id}" }))
+	for _, target := range []string{"http://example.com/users/1", "http://example.com/users/2?status=404"} {
+		resp, err := rt.RoundTrip(httptest.NewRequest("GET", target, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	for _, code := range []string{"200", "404"} {
+		labels := prometheus.Labels{"code": code, "method": "get", "path": "/users/{id}"}
+		if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+			t.Errorf("got %d observations with labels %v, want 1", count, labels)
+		}
+	}
+}
+
+func TestInstrumentRoundTripperDurationLabelNames(t *testing.T) {
+	withPath := WithPathExtractor(func(*http.Request) string { return "/" })
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		opts   []Option
+		panics bool
+	}{
+		{"code and method", []string{"code", "method"}, nil, false},
+		{"missing method", []string{"code"}, nil, true},
+		{"without method", []string{"code"}, []Option{WithoutMethodLabel()}, false},
+		{"path", []string{"code", "method", "path"}, []Option{withPath}, false},
+		{"missing path", []string{"code", "method"}, []Option{withPath}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name: "test_client_request_duration_seconds",
+				Help: "Test client request durations.",
+			}, tc.labels)
+			defer func() {
+				if panicked := recover() != nil; panicked != tc.panics {
+					t.Errorf("panicked: %v, want %v", panicked, tc.panics)
+				}
+			}()
+			InstrumentRoundTripperDuration(obs, statusTransport, tc.opts...)
+		})
+	}
+}
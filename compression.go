@@ -0,0 +1,64 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+)
+
+type compressedSizeRecorder struct {
+	// Accessed atomically; -1 until reported.
+	size int64
+}
+
+// ReportCompressedSize reports the size, in bytes, the response of the
+// request ctx belongs to has after compression. See
+// WithCompressionRatioLabel. It does nothing if the request is not
+// instrumented with that option.
+func ReportCompressedSize(ctx context.Context, size int64) {
+	if rec, ok := ctx.Value(compressedSizeKey).(*compressedSizeRecorder); ok {
+		atomic.StoreInt64(&rec.size, size)
+	}
+}
+
+// WithCompressionRatioLabel adds a "compression_ratio" label bucketing the
+// ratio between the bytes written by the handler and the compressed size
+// reported for the response with ReportCompressedSize: "<2", "2-4", "4-8"
+// or "8+". Responses for which no compressed size was reported, or
+// without a body, are labeled "none". This points at endpoints whose
+// responses compress poorly.
+//
+// The instrument has to sit between the compression and the handler, so
+// that what it counts is the uncompressed body, and the handler (or the
+// compression writer it was given) has to report the compressed size
+// before returning.
+func WithCompressionRatioLabel() Option {
+	return func(o *options) {
+		o.contextHooks = append(o.contextHooks, func(ctx context.Context) context.Context {
+			return context.WithValue(ctx, compressedSizeKey, &compressedSizeRecorder{size: -1})
+		})
+		o.addLabel("compression_ratio", func(r *http.Request, d Delegator) string {
+			rec, ok := r.Context().Value(compressedSizeKey).(*compressedSizeRecorder)
+			if !ok {
+				return noneLabelValue
+			}
+			return compressionRatioBucket(d.Written(), atomic.LoadInt64(&rec.size))
+		})
+	}
+}
+
+func compressionRatioBucket(uncompressed, compressed int64) string {
+	if uncompressed <= 0 || compressed <= 0 {
+		return noneLabelValue
+	}
+	switch ratio := float64(uncompressed) / float64(compressed); {
+	case ratio < 2:
+		return "<2"
+	case ratio < 4:
+		return "2-4"
+	case ratio < 8:
+		return "4-8"
+	default:
+		return "8+"
+	}
+}
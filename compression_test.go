@@ -0,0 +1,43 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithCompressionRatioLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		written    int
+		compressed int64 // 0: not reported
+		want       string
+	}{
+		{"3:1", 300, 100, "2-4"},
+		{"1:1", 100, 100, "<2"},
+		{"2:1", 200, 100, "2-4"},
+		{"4:1", 400, 100, "4-8"},
+		{"10:1", 1000, 100, "8+"},
+		{"not reported", 300, 0, noneLabelValue},
+		{"no body", 0, 100, noneLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("compression_ratio")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(make([]byte, tc.written))
+				if tc.compressed > 0 {
+					ReportCompressedSize(r.Context(), tc.compressed)
+				}
+			}), WithCompressionRatioLabel())
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"compression_ratio": tc.want}); count != 1 {
+				t.Errorf("got %d observations for %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
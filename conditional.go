@@ -0,0 +1,23 @@
+package prom_mux
+
+import "net/http"
+
+// WithConditionalLabel adds a "conditional" label telling how a request
+// carrying an If-None-Match or If-Modified-Since header was answered:
+// "not_modified" for a 304, "modified" for any other status. Requests
+// without either header are labeled "none". The ratio of the first two
+// shows how well clients' cache validation works.
+func WithConditionalLabel() Option {
+	return func(o *options) {
+		o.addLabel("conditional", func(r *http.Request, d Delegator) string {
+			if r.Header.Get("If-None-Match") == "" &&
+				r.Header.Get("If-Modified-Since") == "" {
+				return noneLabelValue
+			}
+			if o.status(r, d) == http.StatusNotModified {
+				return "not_modified"
+			}
+			return "modified"
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithConditionalLabel(t *testing.T) {
+	modTime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "a.txt", modTime, strings.NewReader("content"))
+	})
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		code   string
+		want   string
+	}{
+		{"unconditional", http.Header{}, "200", noneLabelValue},
+		{"etag match", http.Header{"If-None-Match": {`"v1"`}}, "304", "not_modified"},
+		{"etag mismatch", http.Header{"If-None-Match": {`"v0"`}}, "200", "modified"},
+		{"not modified since", http.Header{"If-Modified-Since": {modTime.Format(http.TimeFormat)}}, "304", "not_modified"},
+		{"modified since", http.Header{"If-Modified-Since": {modTime.Add(-time.Hour).Format(http.TimeFormat)}}, "200", "modified"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("conditional")
+			r := httptest.NewRequest("GET", "/a.txt", nil)
+			r.Header = tc.header
+			InstrumentHandlerDuration(obs, h, WithConditionalLabel()).ServeHTTP(httptest.NewRecorder(), r)
+
+			count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.code, "conditional": tc.want})
+			if count != 1 {
+				t.Errorf("got %d observations for %s %q, want 1", count, tc.code, tc.want)
+			}
+		})
+	}
+}
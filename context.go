@@ -0,0 +1,76 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type contextKey int
+
+const (
+	startTimeKey contextKey = iota
+	variantKey
+	latencyClassKey
+	subrequestKey
+	featureFlagsKey
+	entryLabelsKey
+	dbWaitKey
+	compressedSizeKey
+	cacheOutcomeKey
+	acceptTimeKey
+)
+
+// ContextWithStartTime returns a copy of ctx carrying t as the start time of
+// the request, as used by instruments configured WithCumulativeDuration.
+func ContextWithStartTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, startTimeKey, t)
+}
+
+// StartTimeFromContext returns the request start time stored in ctx, if any.
+func StartTimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(startTimeKey).(time.Time)
+	return t, ok
+}
+
+// WithCumulativeDuration makes the instrument measure from the start time
+// found in the request context instead of from its own entry. If there is
+// none yet, the instrument stores its own start time for the handlers it
+// wraps. Nesting several instruments configured this way therefore records,
+// at each stage, the latency accumulated since the outermost one was
+// entered.
+func WithCumulativeDuration() Option {
+	return func(o *options) {
+		o.cumulative = true
+	}
+}
+
+// start returns the time the observation should be measured from and the
+// request to pass on to the wrapped handler, carrying whatever the options
+// need to find in its context once the handler has returned.
+func (o *options) start(r *http.Request) (time.Time, *http.Request) {
+	now := o.now()
+	if len(o.contextHooks) > 0 {
+		ctx := r.Context()
+		for _, hook := range o.contextHooks {
+			ctx = hook(ctx)
+		}
+		r = r.WithContext(ctx)
+	}
+	if o.entryLabels {
+		values := make(map[string]string)
+		for _, l := range o.extraLabels {
+			if l.entry {
+				values[l.name] = l.value(r, nil)
+			}
+		}
+		r = r.WithContext(context.WithValue(r.Context(), entryLabelsKey, values))
+	}
+	if !o.cumulative {
+		return now, r
+	}
+	if t, ok := StartTimeFromContext(r.Context()); ok {
+		return t, r
+	}
+	return now, r.WithContext(ContextWithStartTime(r.Context(), now))
+}
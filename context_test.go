@@ -0,0 +1,50 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithCumulativeDuration(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		opts      []Option
+		wantInner float64
+	}{
+		{"cumulative", []Option{WithCumulativeDuration()}, 0.3},
+		{"own entry", nil, 0.2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			outerObs, innerObs := newDurationVec(), newDurationVec()
+			inner := InstrumentHandlerDuration(innerObs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				clock.Advance(200 * time.Millisecond)
+			}), append([]Option{WithClock(clock.Now)}, tc.opts...)...)
+			outer := InstrumentHandlerDuration(outerObs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(100 * time.Millisecond)
+				inner.ServeHTTP(w, r)
+			}), append([]Option{WithClock(clock.Now)}, tc.opts...)...)
+			outer.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			for _, obs := range []struct {
+				name string
+				vec  prometheus.Collector
+				want float64
+			}{
+				{"outer", outerObs, 0.3},
+				{"inner", innerObs, tc.wantInner},
+			} {
+				count, sum := prommuxtest.CollectObservation(t, obs.vec, prometheus.Labels{"code": "200"})
+				if count != 1 || !approx(sum, obs.want) {
+					t.Errorf("%s: got %d observations summing to %v, want 1 of %v", obs.name, count, sum, obs.want)
+				}
+			}
+		})
+	}
+}
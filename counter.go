@@ -0,0 +1,38 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerCounter increments counter once for every request
+// handled by next, with the same labels InstrumentHandlerDuration would
+// use. The counter is incremented after next returned, so it carries the
+// final status; handlers that only call Write are counted as 200.
+//
+// Rates and error ratios can be computed from the _count series of a
+// duration histogram as well, but a counter is cheaper and stays valid
+// when the histogram's buckets or labels change.
+func InstrumentHandlerCounter(
+	counter *prometheus.CounterVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerCounter", "counter", counter)
+	mustNotBeNil("InstrumentHandlerCounter", "handler", next)
+	o := newOptions(opts)
+	counter = o.curryCounter(counter)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		labels := o.labels(r, d)
+		o.add(counter.With(labels), 1, r, d, o.now().Sub(now))
+		releaseLabels(labels)
+		releaseDelegator(rwd)
+	}
+}
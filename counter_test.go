@@ -0,0 +1,58 @@
+package prom_mux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerCounter(t *testing.T) {
+	counter := newCounterVec()
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return InstrumentHandlerCounter(counter, next)
+	})
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, "user")
+	})
+	router.HandleFunc("/orders", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}).Methods("POST")
+	for _, req := range []struct{ method, target string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"GET", "/users/0"},
+		{"DELETE", "/users/1"},
+		{"POST", "/orders"},
+		{"POST", "/orders"},
+		{"POST", "/orders"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.target, nil))
+	}
+
+	for _, tc := range []struct {
+		code, method, path string
+		want               uint64
+	}{
+		{"200", "get", "/users/{id}", 2},
+		{"404", "get", "/users/{id}", 1},
+		{"200", "delete", "/users/{id}", 1},
+		{"201", "post", "/orders", 3},
+		{"200", "post", "/orders", 0},
+	} {
+		labels := prometheus.Labels{"code": tc.code, "method": tc.method, "path": tc.path}
+		if count, _ := prommuxtest.CollectObservation(t, counter, labels); count != tc.want {
+			t.Errorf("got a count of %d for %v, want %d", count, labels, tc.want)
+		}
+	}
+}
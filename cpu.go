@@ -0,0 +1,58 @@
+package prom_mux
+
+import (
+	"net/http"
+	"runtime"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerCPUTime observes with obs the CPU time, in seconds, next
+// spent serving a request, as opposed to the wall clock time observed by
+// InstrumentHandlerDuration. obs is partitioned like for the latter.
+// Comparing both tells CPU bound handlers from those waiting on I/O.
+//
+// The goroutine serving the request is locked to its OS thread while next
+// runs, and the CPU time used by that thread is measured. This is only
+// supported on Linux; elsewhere next is returned as is, so nothing is
+// observed and no thread is locked. It is an approximation: work done by
+// goroutines next starts is not included, while garbage collection work
+// the goroutine is asked to assist with is. Locking the thread makes the
+// runtime start other threads for the remaining goroutines, so enable it
+// selectively on busy servers.
+func InstrumentHandlerCPUTime(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerCPUTime", "observer", obs)
+	mustNotBeNil("InstrumentHandlerCPUTime", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	if _, ok := threadCPUTime(); !ok {
+		return next.ServeHTTP
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		before, ok := threadCPUTime()
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		d := newDelegator(w, nil)
+		next.ServeHTTP(d, r)
+		after, ok := threadCPUTime()
+		if !ok {
+			return
+		}
+
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), (after - before).Seconds(), r, d, o.now().Sub(now))
+		releaseLabels(labels)
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package prom_mux
+
+import (
+	"syscall"
+	"time"
+)
+
+// rusageThread is RUSAGE_THREAD, which the syscall package doesn't define.
+const rusageThread = 1
+
+// threadCPUTime returns the user and system CPU time used by the calling
+// OS thread so far.
+func threadCPUTime() (time.Duration, bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &ru); err != nil {
+		return 0, false
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()), true
+}
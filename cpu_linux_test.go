@@ -0,0 +1,68 @@
+//go:build linux
+// +build linux
+
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// spin keeps the CPU busy for at least d of wall clock time.
+func spin(d time.Duration) {
+	x := 0
+	for start := time.Now(); time.Since(start) < d; {
+		for i := 0; i < 1000; i++ {
+			x += i
+		}
+	}
+	_ = x
+}
+
+func TestInstrumentHandlerCPUTime(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		busy bool
+	}{
+		{"busy", true},
+		{"sleeping", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return InstrumentHandlerCPUTime(obs, next)
+			})
+			router.HandleFunc("/work/{id}", func(w http.ResponseWriter, _ *http.Request) {
+				if tc.busy {
+					spin(50 * time.Millisecond)
+				} else {
+					time.Sleep(50 * time.Millisecond)
+				}
+				w.WriteHeader(http.StatusAccepted)
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/work/1", nil))
+
+			labels := prometheus.Labels{"code": "202", "method": "post", "path": "/work/{id}"}
+			count, cpu := prommuxtest.CollectObservation(t, obs, labels)
+			if count != 1 {
+				t.Fatalf("got %d observations with labels %v, want 1", count, labels)
+			}
+			// The thread's CPU time is counted in clock ticks, so allow
+			// for some slack either way.
+			if tc.busy && cpu < 0.02 {
+				t.Errorf("busy handler used %vs of CPU, want about 0.05s", cpu)
+			}
+			if !tc.busy && cpu > 0.02 {
+				t.Errorf("sleeping handler used %vs of CPU, want about 0", cpu)
+			}
+		})
+	}
+}
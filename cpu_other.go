@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package prom_mux
+
+import "time"
+
+// threadCPUTime is not supported on this platform.
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
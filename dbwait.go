@@ -0,0 +1,66 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type dbWaitRecorder struct {
+	// Accessed atomically.
+	wait     int64
+	reported int32
+}
+
+// InstrumentHandlerDBWait observes with obs, in seconds, the total time the
+// handlers wrapped by next reported with ReportDBWait for a request, i.e.
+// the time spent waiting for database connections. obs is partitioned like
+// for InstrumentHandlerDuration. Requests for which nothing was reported
+// are not observed.
+//
+// This surfaces connection pool saturation, which otherwise hides inside
+// the total request duration.
+func InstrumentHandlerDBWait(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerDBWait", "observer", obs)
+	mustNotBeNil("InstrumentHandlerDBWait", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		_, r = o.start(r)
+		rec := &dbWaitRecorder{}
+		r = r.WithContext(context.WithValue(r.Context(), dbWaitKey, rec))
+		d := newDelegator(w, nil)
+		next.ServeHTTP(d, r)
+
+		if atomic.LoadInt32(&rec.reported) == 0 {
+			return
+		}
+		wait := time.Duration(atomic.LoadInt64(&rec.wait))
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), wait.Seconds(), r, d, wait)
+		releaseLabels(labels)
+	}
+}
+
+// ReportDBWait adds wait to the time the request ctx belongs to spent
+// acquiring database connections. Call it after every acquisition, e.g.
+// with the time taken by sql.DB.Conn or the pool's equivalent. It does
+// nothing if the request is not instrumented with
+// InstrumentHandlerDBWait, and is safe for concurrent use.
+func ReportDBWait(ctx context.Context, wait time.Duration) {
+	rec, ok := ctx.Value(dbWaitKey).(*dbWaitRecorder)
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&rec.wait, int64(wait))
+	atomic.StoreInt32(&rec.reported, 1)
+}
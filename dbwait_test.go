@@ -0,0 +1,46 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerDBWait(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		waits []time.Duration
+		count uint64
+		sum   float64
+	}{
+		{"one", []time.Duration{250 * time.Millisecond}, 1, 0.25},
+		{"several", []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 0}, 1, 0.3},
+		{"zero", []time.Duration{0}, 1, 0},
+		{"none", nil, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return InstrumentHandlerDBWait(obs, next)
+			})
+			router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				for _, wait := range tc.waits {
+					ReportDBWait(r.Context(), wait)
+				}
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": "/users/{id}"})
+			if count != tc.count || !approx(sum, tc.sum) {
+				t.Errorf("got %d observations summing to %v, want %d of %v", count, sum, tc.count, tc.sum)
+			}
+		})
+	}
+}
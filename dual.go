@@ -0,0 +1,128 @@
+package prom_mux
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DualObserver returns an ObserverVec that forwards every observation to
+// both hist and summary. It is meant for migrating from a SummaryVec to a
+// HistogramVec: instrument handlers with the returned vector, keep the
+// alerts based on the summary running while the new ones based on the
+// histogram are being validated, then switch to hist alone.
+//
+// Both vectors must be partitioned by the same labels, otherwise
+// DualObserver panics. Curried label values are taken into account, so
+// currying one of them differently is fine as long as the remaining labels
+// match. WithLabelValues passes the values on positionally, so it only
+// works as expected if both vectors declare their labels in the same order.
+//
+// The returned vector collects both hist and summary. Register either it or
+// the two vectors, not both.
+func DualObserver(hist, summary prometheus.ObserverVec) prometheus.ObserverVec {
+	mustNotBeNil("DualObserver", "histogram", hist)
+	mustNotBeNil("DualObserver", "summary", summary)
+	h, err := labelNames(hist)
+	if err != nil {
+		panic(err)
+	}
+	s, err := labelNames(summary)
+	if err != nil {
+		panic(err)
+	}
+	if !sameLabels(h, s) {
+		panic(fmt.Sprintf(
+			"histogram labels %q and summary labels %q differ", h, s,
+		))
+	}
+	return &dualObserverVec{hist: hist, summary: summary}
+}
+
+type dualObserverVec struct {
+	hist, summary prometheus.ObserverVec
+}
+
+type dualObserver struct {
+	hist, summary prometheus.Observer
+}
+
+func (o dualObserver) Observe(v float64) {
+	o.hist.Observe(v)
+	o.summary.Observe(v)
+}
+
+// ObserveWithExemplar attaches exemplar to the histogram observation if
+// the histogram supports it. Summaries don't support exemplars.
+func (o dualObserver) ObserveWithExemplar(v float64, exemplar prometheus.Labels) {
+	if eo, ok := o.hist.(exemplarObserver); ok {
+		eo.ObserveWithExemplar(v, exemplar)
+	} else {
+		o.hist.Observe(v)
+	}
+	o.summary.Observe(v)
+}
+
+func (v *dualObserverVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	h, err := v.hist.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	s, err := v.summary.GetMetricWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return dualObserver{hist: h, summary: s}, nil
+}
+
+func (v *dualObserverVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Observer, error) {
+	h, err := v.hist.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		return nil, err
+	}
+	s, err := v.summary.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		return nil, err
+	}
+	return dualObserver{hist: h, summary: s}, nil
+}
+
+func (v *dualObserverVec) With(labels prometheus.Labels) prometheus.Observer {
+	return dualObserver{hist: v.hist.With(labels), summary: v.summary.With(labels)}
+}
+
+func (v *dualObserverVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	return dualObserver{
+		hist:    v.hist.WithLabelValues(lvs...),
+		summary: v.summary.WithLabelValues(lvs...),
+	}
+}
+
+func (v *dualObserverVec) CurryWith(labels prometheus.Labels) (prometheus.ObserverVec, error) {
+	h, err := v.hist.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	s, err := v.summary.CurryWith(labels)
+	if err != nil {
+		return nil, err
+	}
+	return &dualObserverVec{hist: h, summary: s}, nil
+}
+
+func (v *dualObserverVec) MustCurryWith(labels prometheus.Labels) prometheus.ObserverVec {
+	return &dualObserverVec{
+		hist:    v.hist.MustCurryWith(labels),
+		summary: v.summary.MustCurryWith(labels),
+	}
+}
+
+func (v *dualObserverVec) Describe(ch chan<- *prometheus.Desc) {
+	v.hist.Describe(ch)
+	v.summary.Describe(ch)
+}
+
+func (v *dualObserverVec) Collect(ch chan<- prometheus.Metric) {
+	v.hist.Collect(ch)
+	v.summary.Collect(ch)
+}
@@ -0,0 +1,68 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func newDurationSummaryVec(labels ...string) *prometheus.SummaryVec {
+	return prometheus.NewSummaryVec(prometheus.SummaryOpts{
+		Name: "test_request_duration_summary_seconds",
+		Help: "Test request durations.",
+	}, labels)
+}
+
+func TestDualObserver(t *testing.T) {
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	hist := newDurationVec("version")
+	summary := newDurationSummaryVec("code", "method", "path", "version")
+	h := InstrumentHandlerDuration(DualObserver(hist, summary), http.HandlerFunc(
+		func(http.ResponseWriter, *http.Request) {
+			clock.Advance(time.Second)
+		},
+	), WithClock(clock.Now), WithBuildInfoLabel("1.2.3"))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	labels := prometheus.Labels{"code": "200", "version": "1.2.3"}
+	for _, tc := range []struct {
+		name string
+		c    prometheus.Collector
+	}{
+		{"histogram", hist},
+		{"summary", summary},
+	} {
+		if count, sum := prommuxtest.CollectObservation(t, tc.c, labels); count != 2 || sum != 2 {
+			t.Errorf("%s: got %d observations summing to %v, want 2 summing to 2", tc.name, count, sum)
+		}
+	}
+}
+
+func TestDualObserverLabels(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		hist, summary prometheus.ObserverVec
+		panics        bool
+	}{
+		{"same", newDurationVec(), newDurationSummaryVec("path", "method", "code"), false},
+		{"curried", newDurationVec("version"), newDurationSummaryVec("code", "method", "path", "version").
+			MustCurryWith(prometheus.Labels{"version": "1"}), true},
+		{"differ", newDurationVec(), newDurationSummaryVec("code", "path"), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if panicked := recover() != nil; panicked != tc.panics {
+					t.Errorf("panicked: %v, want %v", panicked, tc.panics)
+				}
+			}()
+			DualObserver(tc.hist, tc.summary)
+		})
+	}
+}
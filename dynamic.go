@@ -0,0 +1,273 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type dynamicHistogram struct {
+	mtx        sync.RWMutex
+	reg        prometheus.Registerer
+	opts       prometheus.HistogramOpts
+	labelNames []string
+	vec        *prometheus.HistogramVec
+}
+
+func (h *dynamicHistogram) current() *prometheus.HistogramVec {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.vec
+}
+
+// DynamicHistogramVec is a prometheus.ObserverVec backed by a HistogramVec
+// whose buckets can be changed while the process is running. It registers
+// itself with the Registerer passed to NewDynamicHistogramVec, so it must
+// not be registered again.
+//
+// Changing the buckets unregisters the current HistogramVec and registers a
+// fresh one with the new boundaries. All series observed so far are dropped
+// and start again from zero, which shows up as a counter reset in rate()
+// and friends.
+type DynamicHistogramVec struct {
+	h       *dynamicHistogram
+	curried prometheus.Labels
+}
+
+// NewDynamicHistogramVec creates a HistogramVec from opts and labelNames,
+// registers it with reg and returns it wrapped in a DynamicHistogramVec.
+func NewDynamicHistogramVec(
+	reg prometheus.Registerer,
+	opts prometheus.HistogramOpts,
+	labelNames []string,
+) (*DynamicHistogramVec, error) {
+	vec := prometheus.NewHistogramVec(opts, labelNames)
+	if err := reg.Register(vec); err != nil {
+		return nil, err
+	}
+	return &DynamicHistogramVec{
+		h: &dynamicHistogram{
+			reg:        reg,
+			opts:       opts,
+			labelNames: labelNames,
+			vec:        vec,
+		},
+	}, nil
+}
+
+// Buckets returns the bucket boundaries currently in use. Nil means the
+// prometheus default buckets.
+func (v *DynamicHistogramVec) Buckets() []float64 {
+	v.h.mtx.RLock()
+	defer v.h.mtx.RUnlock()
+	return append([]float64(nil), v.h.opts.Buckets...)
+}
+
+// SetBuckets replaces the underlying HistogramVec with one using buckets.
+// The previous vector is unregistered and all its series are lost. If the
+// new vector cannot be registered, the previous one is restored and the
+// error is returned.
+func (v *DynamicHistogramVec) SetBuckets(buckets []float64) error {
+	if err := validateBuckets(buckets); err != nil {
+		return err
+	}
+
+	h := v.h
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	opts := h.opts
+	opts.Buckets = append([]float64(nil), buckets...)
+	vec := prometheus.NewHistogramVec(opts, h.labelNames)
+	h.reg.Unregister(h.vec)
+	if err := h.reg.Register(vec); err != nil {
+		if err2 := h.reg.Register(h.vec); err2 != nil {
+			return fmt.Errorf("%v (restoring previous histogram: %v)", err, err2)
+		}
+		return err
+	}
+	h.opts = opts
+	h.vec = vec
+	return nil
+}
+
+// AdminHandler returns a handler to inspect and change the buckets of v.
+// Nothing is exposed unless the returned handler is explicitly mounted, so
+// it should be put behind whatever protects other administrative endpoints.
+//
+// A GET request reports the current buckets. A POST request with a
+// comma-separated "buckets" form value (e.g. "buckets=0.1,0.5,1,5") replaces
+// them, dropping every series recorded so far.
+func (v *DynamicHistogramVec) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			buckets, err := parseBuckets(r.FormValue("buckets"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := v.SetBuckets(buckets); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprintln(w, "buckets changed, all previously recorded series were reset")
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fmt.Fprintf(w, "buckets: %v\n", v.Buckets())
+	})
+}
+
+func parseBuckets(s string) ([]float64, error) {
+	if s == "" {
+		return nil, fmt.Errorf("no buckets given")
+	}
+	parts := strings.Split(s, ",")
+	buckets := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		b, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %q: %v", p, err)
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, validateBuckets(buckets)
+}
+
+func validateBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return fmt.Errorf("no buckets given")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf(
+				"buckets must be in strictly increasing order: %v", buckets,
+			)
+		}
+	}
+	return nil
+}
+
+func (v *DynamicHistogramVec) labels(labels prometheus.Labels) (prometheus.Labels, error) {
+	if len(v.curried) == 0 {
+		return labels, nil
+	}
+	merged := make(prometheus.Labels, len(labels)+len(v.curried))
+	for name, value := range labels {
+		if _, ok := v.curried[name]; ok {
+			return nil, fmt.Errorf("label name %q is already curried", name)
+		}
+		merged[name] = value
+	}
+	for name, value := range v.curried {
+		merged[name] = value
+	}
+	return merged, nil
+}
+
+func (v *DynamicHistogramVec) labelValues(lvs []string) (prometheus.Labels, error) {
+	labels := make(prometheus.Labels, len(v.h.labelNames))
+	i := 0
+	for _, name := range v.h.labelNames {
+		if value, ok := v.curried[name]; ok {
+			labels[name] = value
+			continue
+		}
+		if i >= len(lvs) {
+			break
+		}
+		labels[name] = lvs[i]
+		i++
+	}
+	if i != len(lvs) || len(labels) != len(v.h.labelNames) {
+		return nil, fmt.Errorf(
+			"inconsistent label cardinality: expected %d label values but got %d",
+			len(v.h.labelNames)-len(v.curried), len(lvs),
+		)
+	}
+	return labels, nil
+}
+
+// GetMetricWith implements prometheus.ObserverVec.
+func (v *DynamicHistogramVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	labels, err := v.labels(labels)
+	if err != nil {
+		return nil, err
+	}
+	return v.h.current().GetMetricWith(labels)
+}
+
+// GetMetricWithLabelValues implements prometheus.ObserverVec.
+func (v *DynamicHistogramVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Observer, error) {
+	labels, err := v.labelValues(lvs)
+	if err != nil {
+		return nil, err
+	}
+	return v.h.current().GetMetricWith(labels)
+}
+
+// With implements prometheus.ObserverVec.
+func (v *DynamicHistogramVec) With(labels prometheus.Labels) prometheus.Observer {
+	o, err := v.GetMetricWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// WithLabelValues implements prometheus.ObserverVec.
+func (v *DynamicHistogramVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	o, err := v.GetMetricWithLabelValues(lvs...)
+	if err != nil {
+		panic(err)
+	}
+	return o
+}
+
+// CurryWith implements prometheus.ObserverVec. The curried vector keeps
+// following bucket changes of v.
+func (v *DynamicHistogramVec) CurryWith(labels prometheus.Labels) (prometheus.ObserverVec, error) {
+	curried, err := v.labels(labels)
+	if err != nil {
+		return nil, err
+	}
+	for name := range labels {
+		if !containsString(v.h.labelNames, name) {
+			return nil, fmt.Errorf("label name %q not found in histogram", name)
+		}
+	}
+	if len(v.curried) == 0 {
+		curried = make(prometheus.Labels, len(labels))
+		for name, value := range labels {
+			curried[name] = value
+		}
+	}
+	return &DynamicHistogramVec{h: v.h, curried: curried}, nil
+}
+
+// MustCurryWith implements prometheus.ObserverVec.
+func (v *DynamicHistogramVec) MustCurryWith(labels prometheus.Labels) prometheus.ObserverVec {
+	vec, err := v.CurryWith(labels)
+	if err != nil {
+		panic(err)
+	}
+	return vec
+}
+
+// Describe implements prometheus.Collector.
+func (v *DynamicHistogramVec) Describe(ch chan<- *prometheus.Desc) {
+	v.h.current().Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (v *DynamicHistogramVec) Collect(ch chan<- prometheus.Metric) {
+	v.h.current().Collect(ch)
+}
@@ -0,0 +1,74 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestDynamicHistogramVec(t *testing.T) {
+	vec, err := NewDynamicHistogramVec(prometheus.NewRegistry(), prometheus.HistogramOpts{
+		Name:    "test_request_duration_seconds",
+		Help:    "Test request durations.",
+		Buckets: []float64{1, 2},
+	}, []string{"code", "method", "path", "version"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := InstrumentHandlerDuration(vec, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithBuildInfoLabel("1.2.3"))
+	labels := prometheus.Labels{"code": "200", "version": "1.2.3"}
+	admin := vec.AdminHandler()
+
+	for i, step := range []struct {
+		buckets string // POSTed to the admin handler first, if not empty
+		status  int
+		want    []float64
+		count   uint64
+	}{
+		{"", http.StatusOK, []float64{1, 2}, 1},
+		{"0.1, 0.5,1", http.StatusOK, []float64{0.1, 0.5, 1}, 1},
+		{"1,x", http.StatusBadRequest, []float64{0.1, 0.5, 1}, 2},
+		{"1,0.5", http.StatusBadRequest, []float64{0.1, 0.5, 1}, 3},
+	} {
+		if step.buckets != "" {
+			form := url.Values{"buckets": {step.buckets}}.Encode()
+			r := httptest.NewRequest("POST", "/buckets", strings.NewReader(form))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rec := httptest.NewRecorder()
+			admin.ServeHTTP(rec, r)
+			if rec.Code != step.status {
+				t.Errorf("step %d: admin handler answered %d, want %d", i, rec.Code, step.status)
+			}
+		}
+		if got := vec.Buckets(); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("step %d: buckets are %v, want %v", i, got, step.want)
+		}
+		// Changing the buckets drops the series observed so far.
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		if count, _ := prommuxtest.CollectObservation(t, vec, labels); count != step.count {
+			t.Errorf("step %d: got %d observations, want %d", i, count, step.count)
+		}
+	}
+
+	for _, tc := range []struct {
+		method string
+		want   int
+	}{
+		{"GET", http.StatusOK},
+		{"PUT", http.StatusMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		admin.ServeHTTP(rec, httptest.NewRequest(tc.method, "/buckets", nil))
+		if rec.Code != tc.want {
+			t.Errorf("%s: admin handler answered %d, want %d", tc.method, rec.Code, tc.want)
+		}
+	}
+}
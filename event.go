@@ -0,0 +1,50 @@
+package prom_mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Event describes a single instrumented request. It carries the same values
+// that are used as labels and observation for the prometheus metric, so log
+// based pipelines can derive the same metrics from it.
+type Event struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Code     int               `json:"code"`
+	Duration time.Duration     `json:"duration"`
+	Written  int64             `json:"bytes"`
+	Labels   prometheus.Labels `json:"labels,omitempty"`
+}
+
+// WithEventSink makes the instrument call sink with an Event once per
+// request, after the prometheus observation has been made. sink is called
+// synchronously on the request goroutine, so it should not block.
+func WithEventSink(sink func(Event)) Option {
+	return func(o *options) {
+		o.eventSink = sink
+	}
+}
+
+func (o *options) emitEvent(
+	r *http.Request, d Delegator, labels prometheus.Labels, elapsed time.Duration,
+) {
+	if o.eventSink == nil {
+		return
+	}
+	code := effectiveCode(o.status(r, d))
+	eventLabels := make(prometheus.Labels, len(labels))
+	for name, value := range labels {
+		eventLabels[name] = value
+	}
+	o.eventSink(Event{
+		Method:   o.method(r),
+		Path:     o.path(r),
+		Code:     code,
+		Duration: elapsed,
+		Written:  d.Written(),
+		Labels:   eventLabels,
+	})
+}
@@ -0,0 +1,75 @@
+package prom_mux
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithEventSink(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+		want   Event
+	}{
+		{"explicit", http.StatusCreated, "created", Event{
+			Method: "post", Path: defaultUnmatchedPath, Code: 201,
+			Duration: 250 * time.Millisecond, Written: 7,
+			Labels: prometheus.Labels{"code": "201", "method": "post", "path": defaultUnmatchedPath},
+		}},
+		{"nothing written", 0, "", Event{
+			Method: "post", Path: defaultUnmatchedPath, Code: 200,
+			Duration: 250 * time.Millisecond,
+			Labels:   prometheus.Labels{"code": "200", "method": "post", "path": defaultUnmatchedPath},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec()
+			var events []Event
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(250 * time.Millisecond)
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				w.Write([]byte(tc.body))
+			}), WithClock(clock.Now), WithEventSink(func(e Event) {
+				events = append(events, e)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			if !reflect.DeepEqual(events[0], tc.want) {
+				t.Errorf("got event %+v, want %+v", events[0], tc.want)
+			}
+			// The event carries what was observed.
+			count, sum := prommuxtest.CollectObservation(t, obs, events[0].Labels)
+			if count != 1 || sum != events[0].Duration.Seconds() {
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, events[0].Duration.Seconds())
+			}
+		})
+	}
+}
+
+func TestEventJSON(t *testing.T) {
+	b, err := json.Marshal(Event{
+		Method: "get", Path: "/users/{id}", Code: 404, Duration: time.Second, Written: 9,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"method":"get","path":"/users/{id}","code":404,"duration":1000000000,"bytes":9}`
+	if string(b) != want {
+		t.Errorf("got %s, want %s", b, want)
+	}
+}
@@ -0,0 +1,124 @@
+package prom_mux
+
+import (
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultRateDecay = time.Minute
+
+// RateEWMAOpts configures a RateEWMA collector.
+type RateEWMAOpts struct {
+	Namespace   string
+	Subsystem   string
+	Name        string
+	Help        string
+	ConstLabels prometheus.Labels
+
+	// Decay is the time constant of the moving average: the weight of a
+	// request drops by a factor of e every Decay. Larger values give a
+	// smoother but slower reacting rate. Defaults to one minute.
+	Decay time.Duration
+}
+
+// RateEWMA is a collector exposing, per "path" label, an exponentially
+// weighted moving average of the request rate in requests per second, fed
+// by InstrumentHandlerRateEWMA.
+//
+// This is an approximation computed in-process, meant for dashboards that
+// want a ready-made smoothed rate. Under steady traffic it converges to the
+// true rate within a few Decay periods. rate() over a counter is more
+// accurate and aggregates properly across instances, so prefer it where
+// possible.
+type RateEWMA struct {
+	desc  *prometheus.Desc
+	decay float64
+
+	mtx   sync.Mutex
+	rates map[string]*ewmaRate
+}
+
+type ewmaRate struct {
+	rate float64
+	last time.Time
+}
+
+// at returns the rate decayed to t.
+func (e *ewmaRate) at(t time.Time, decay float64) float64 {
+	return e.rate * math.Exp(-t.Sub(e.last).Seconds()/decay)
+}
+
+// NewRateEWMA creates a RateEWMA collector. It has to be registered to be
+// exported.
+func NewRateEWMA(opts RateEWMAOpts) *RateEWMA {
+	if opts.Decay <= 0 {
+		opts.Decay = defaultRateDecay
+	}
+	if opts.Help == "" {
+		opts.Help = "Exponentially weighted moving average of the request rate per second."
+	}
+	return &RateEWMA{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			opts.Help, []string{"path"}, opts.ConstLabels,
+		),
+		decay: opts.Decay.Seconds(),
+		rates: make(map[string]*ewmaRate),
+	}
+}
+
+func (e *RateEWMA) observe(path string, now time.Time) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	r, ok := e.rates[path]
+	if !ok {
+		r = &ewmaRate{last: now}
+		e.rates[path] = r
+	}
+	// Every request adds an impulse of 1/decay, which makes the average
+	// converge to the number of requests per second.
+	r.rate = r.at(now, e.decay) + 1/e.decay
+	r.last = now
+}
+
+// Describe implements prometheus.Collector.
+func (e *RateEWMA) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.desc
+}
+
+// Collect implements prometheus.Collector.
+func (e *RateEWMA) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	for path, r := range e.rates {
+		ch <- prometheus.MustNewConstMetric(
+			e.desc, prometheus.GaugeValue, r.at(now, e.decay), path,
+		)
+	}
+}
+
+// InstrumentHandlerRateEWMA updates e with every request served by next,
+// keyed by the "path" label.
+func InstrumentHandlerRateEWMA(
+	e *RateEWMA, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerRateEWMA", "RateEWMA", e)
+	mustNotBeNil("InstrumentHandlerRateEWMA", "handler", next)
+	o := newOptions(opts)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+		// The average always follows the wall clock, as that's what
+		// Collect looks at.
+		e.observe(o.path(r), time.Now())
+	}
+}
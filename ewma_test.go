@@ -0,0 +1,48 @@
+package prom_mux
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerRateEWMA(t *testing.T) {
+	// With a decay of an hour, a burst of requests barely decays before
+	// being collected, so the rate is about requests/3600.
+	e := NewRateEWMA(RateEWMAOpts{Name: "rate", Decay: time.Hour})
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return InstrumentHandlerRateEWMA(e, next)
+	})
+	noop := func(http.ResponseWriter, *http.Request) {}
+	router.HandleFunc("/a", noop)
+	router.HandleFunc("/b", noop)
+	router.HandleFunc("/c", noop)
+	for i := 0; i < 36; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/a", nil))
+	}
+	for i := 0; i < 360; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/b", nil))
+	}
+
+	for _, tc := range []struct {
+		path string
+		want float64
+	}{
+		{"/a", 0.01},
+		{"/b", 0.1},
+		{"/c", 0},
+	} {
+		_, rate := prommuxtest.CollectObservation(t, e, prometheus.Labels{"path": tc.path})
+		if tc.want == 0 && rate != 0 || tc.want != 0 && math.Abs(rate-tc.want) > 1e-3*tc.want {
+			t.Errorf("got rate %v for %q, want %v", rate, tc.path, tc.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// exemplarObserver is implemented by observers able to attach an exemplar
+// to an observation, like the histograms of client_golang releases that
+// support exemplars.
+type exemplarObserver interface {
+	ObserveWithExemplar(value float64, exemplar prometheus.Labels)
+}
+
+// exemplarAdder is the counterpart of exemplarObserver for counters.
+type exemplarAdder interface {
+	AddWithExemplar(value float64, exemplar prometheus.Labels)
+}
+
+// WithExemplarFromContext makes the instrument attach the labels returned by
+// fn, typically {"trace_id": "..."} taken from the trace context, as an
+// exemplar to its observations, and to the increments of its counters. If
+// fn returns nil or the observer does not support exemplars, the value is
+// observed without one. InstrumentRoundTripperDuration ignores it.
+func WithExemplarFromContext(fn func(context.Context) prometheus.Labels) Option {
+	return func(o *options) {
+		o.exemplar = fn
+	}
+}
+
+// WithExemplarSampling restricts exemplars to the requests for which sample
+// returns true, e.g. slow requests or those answered with a 5xx status. All
+// other requests are observed without an exemplar. It has no effect without
+// WithExemplarFromContext. sample must not keep d around: it is reused for
+// later requests.
+func WithExemplarSampling(sample func(d Delegator, elapsed time.Duration) bool) Option {
+	return func(o *options) {
+		o.exemplarSampling = sample
+	}
+}
+
+// observe observes v with obs, attaching an exemplar if configured and
+// supported.
+func (o *options) observe(
+	obs prometheus.Observer, v float64,
+	r *http.Request, d Delegator, elapsed time.Duration,
+) {
+	if eo, ok := obs.(exemplarObserver); ok {
+		if exemplar := o.exemplarFor(r, d, elapsed); exemplar != nil {
+			eo.ObserveWithExemplar(v, exemplar)
+			return
+		}
+	}
+	obs.Observe(v)
+}
+
+// add adds v to counter, attaching an exemplar if configured and supported.
+func (o *options) add(
+	counter prometheus.Counter, v float64,
+	r *http.Request, d Delegator, elapsed time.Duration,
+) {
+	if ea, ok := counter.(exemplarAdder); ok {
+		if exemplar := o.exemplarFor(r, d, elapsed); exemplar != nil {
+			ea.AddWithExemplar(v, exemplar)
+			return
+		}
+	}
+	counter.Add(v)
+}
+
+// exemplarFor returns the exemplar to attach for r, or nil.
+func (o *options) exemplarFor(
+	r *http.Request, d Delegator, elapsed time.Duration,
+) prometheus.Labels {
+	if o.exemplar == nil {
+		return nil
+	}
+	if o.exemplarSampling != nil && !o.exemplarSampling(d, elapsed) {
+		return nil
+	}
+	return o.exemplar(r.Context())
+}
@@ -0,0 +1,115 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// exemplarVec is a HistogramVec whose observers support exemplars and
+// record the ones they are given.
+type exemplarVec struct {
+	*prometheus.HistogramVec
+	exemplars *[]prometheus.Labels
+}
+
+func (v exemplarVec) With(labels prometheus.Labels) prometheus.Observer {
+	return exemplarRecorder{v.HistogramVec.With(labels), v.exemplars}
+}
+
+type exemplarRecorder struct {
+	prometheus.Observer
+	exemplars *[]prometheus.Labels
+}
+
+func (r exemplarRecorder) ObserveWithExemplar(v float64, exemplar prometheus.Labels) {
+	r.Observe(v)
+	*r.exemplars = append(*r.exemplars, exemplar)
+}
+
+func TestExemplarsInEveryInstrument(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.Write([]byte("hello"))
+	})
+	opt := WithExemplarFromContext(func(context.Context) prometheus.Labels {
+		return prometheus.Labels{"trace_id": "abc"}
+	})
+	for _, tc := range []struct {
+		name  string
+		build func(obs prometheus.ObserverVec) http.Handler
+		want  int
+	}{
+		{"ResponseSize", func(obs prometheus.ObserverVec) http.Handler {
+			return InstrumentHandlerResponseSize(obs, next, opt)
+		}, 1},
+		{"RequestSize", func(obs prometheus.ObserverVec) http.Handler {
+			return InstrumentHandlerRequestSize(obs, next, opt)
+		}, 1},
+		{"RequestHeaders", func(obs prometheus.ObserverVec) http.Handler {
+			return InstrumentHandlerRequestHeaders(obs, obs, next, opt)
+		}, 2},
+		{"RetryAfter", func(obs prometheus.ObserverVec) http.Handler {
+			return InstrumentHandlerRetryAfter(obs, next, opt)
+		}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var exemplars []prometheus.Labels
+			h := tc.build(exemplarVec{newDurationVec(), &exemplars})
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if len(exemplars) != tc.want {
+				t.Fatalf("got %d exemplars, want %d", len(exemplars), tc.want)
+			}
+			for _, e := range exemplars {
+				if e["trace_id"] != "abc" {
+					t.Errorf("got exemplar %v, want trace_id abc", e)
+				}
+			}
+		})
+	}
+}
+
+func TestWithExemplarSampling(t *testing.T) {
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	var exemplars []prometheus.Labels
+	obs := exemplarVec{newDurationVec(), &exemplars}
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			clock.Advance(2 * time.Second)
+		}
+		if r.URL.Path == "/error" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}), WithClock(clock.Now), WithExemplarFromContext(func(context.Context) prometheus.Labels {
+		return prometheus.Labels{"trace_id": "abc"}
+	}), WithExemplarSampling(func(d Delegator, elapsed time.Duration) bool {
+		return elapsed > time.Second || d.Status() >= 500
+	}))
+
+	for _, tc := range []struct {
+		path     string
+		exemplar bool
+	}{
+		{"/fast", false},
+		{"/slow", true},
+		{"/error", true},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			exemplars = nil
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.path, nil))
+			if got := len(exemplars) == 1; got != tc.exemplar {
+				t.Errorf("got %d exemplars, want exemplar: %v", len(exemplars), tc.exemplar)
+			}
+		})
+	}
+	// Requests without an exemplar are observed all the same.
+	if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"}); count != 2 {
+		t.Errorf("got %d observations with code 200, want 2", count)
+	}
+}
@@ -0,0 +1,75 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerDurationFast is like InstrumentHandlerDuration, but
+// passes the label values to obs positionally with WithLabelValues rather
+// than in a map, which spares hashing the map and makes the instrument
+// markedly faster on the hottest endpoints. It doesn't save allocations:
+// the slice of label values costs one per request, where the pooled map of
+// InstrumentHandlerDuration costs none. Apart from curried labels,
+// including those of WithConstLabels, obs must have exactly the labels
+// "code", "method" and "path", declared in this order. It panics if it
+// hasn't, unless its labels can't be determined, like those of
+// DualObserver.
+//
+// Options adding, renaming or dropping labels can't be supported and make it
+// panic, as do those observing with other vectors, like WithClassObservers,
+// and those doing more than the observation: WithEventSink, WithSpanEvent,
+// WithPushCounter, WithInformationalCounter, WithAcceptLatency and
+// WithRawPathSampling. Options only changing the values, like
+// WithRouteGroups or WithStatusClassLabel, work as usual.
+func InstrumentHandlerDurationFast(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerDurationFast", "observer", obs)
+	mustNotBeNil("InstrumentHandlerDurationFast", "handler", next)
+	o := newOptions(opts)
+	if len(o.extraLabels) > 0 || len(o.labelsFuncs) > 0 || o.customLabels || o.routeHash ||
+		len(o.classObservers) > 0 || o.methodObserver != nil {
+		panic("InstrumentHandlerDurationFast: options changing the label set are not supported")
+	}
+	if o.eventSink != nil || o.spanFromContext != nil || o.pushCounter != nil ||
+		o.informationalCounter != nil || o.acceptObserver != nil || o.rawPathCounter != nil {
+		panic("InstrumentHandlerDurationFast: options recording more than the duration are not supported")
+	}
+	o.checkLabelNames(obs, o.codeLabel, o.methodLabel, o.pathLabel)
+	obs = o.curryConst(obs)
+	want := []string{o.codeLabel, o.methodLabel, o.pathLabel}
+	if have, err := orderedLabelNames(obs); err == nil && !equalStrings(have, want) {
+		panic(fmt.Sprintf(
+			"InstrumentHandlerDurationFast: observer has labels %q, want %q in this order", have, want,
+		))
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		elapsed := o.now().Sub(now)
+		observer := obs.WithLabelValues(o.code(r, d), o.method(r), o.path(r))
+		o.observe(observer, elapsed.Seconds(), r, d, elapsed)
+		releaseDelegator(rwd)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerDurationFast(t *testing.T) {
+	obs := newDurationVec()
+	h := InstrumentHandlerDurationFast(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/", nil))
+	labels := prometheus.Labels{"code": "202", "method": "put", "path": defaultUnmatchedPath}
+	if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+		t.Errorf("got %d observations with labels %v, want 1", count, labels)
+	}
+}
+
+func TestInstrumentHandlerDurationFastLabels(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		opts   []Option
+		panics bool
+	}{
+		{"default", []string{"code", "method", "path"}, nil, false},
+		{"const", []string{"code", "service", "method", "path"}, []Option{
+			WithConstLabels(prometheus.Labels{"service": "api"}),
+		}, false},
+		{"order", []string{"method", "code", "path"}, nil, true},
+		{"missing", []string{"code", "path"}, nil, true},
+		{"extra", []string{"code", "method", "path", "host"}, nil, true},
+		{"event sink", []string{"code", "method", "path"}, []Option{WithEventSink(func(Event) {})}, true},
+		{"push counter", []string{"code", "method", "path"}, []Option{WithPushCounter(newCounterVec())}, true},
+		{"accept latency", []string{"code", "method", "path"}, []Option{WithAcceptLatency(newDurationVec())}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name: "test_request_duration_seconds",
+				Help: "Test request durations.",
+			}, tc.labels)
+			defer func() {
+				if r := recover(); (r != nil) != tc.panics {
+					t.Errorf("got panic %v, want one: %v", r, tc.panics)
+				}
+			}()
+			InstrumentHandlerDurationFast(obs, http.NotFoundHandler(), tc.opts...)
+		})
+	}
+}
+
+// BenchmarkInstrumentHandlerDurationLabels compares passing the labels
+// positionally to passing them in a map.
+func BenchmarkInstrumentHandlerDurationLabels(b *testing.B) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, bc := range []struct {
+		name string
+		h    http.Handler
+	}{
+		{"map", InstrumentHandlerDuration(newDurationVec(), next)},
+		{"positional", InstrumentHandlerDurationFast(newDurationVec(), next)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := httptest.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bc.h.ServeHTTP(w, r)
+			}
+		})
+	}
+}
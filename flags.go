@@ -0,0 +1,64 @@
+package prom_mux
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// ContextWithFeatureFlags returns a copy of ctx carrying the names of the
+// feature flags enabled for the request. See WithFeatureFlagLabel.
+func ContextWithFeatureFlags(ctx context.Context, flags ...string) context.Context {
+	return context.WithValue(ctx, featureFlagsKey, flags)
+}
+
+// FeatureFlagsFromContext returns the feature flags stored in ctx.
+func FeatureFlagsFromContext(ctx context.Context) []string {
+	flags, _ := ctx.Value(featureFlagsKey).([]string)
+	return flags
+}
+
+// FeatureFlagBucket hashes the combination of enabled flags into one of n
+// buckets, named "0" to n-1. The order of flags doesn't matter, duplicates
+// are ignored. Without any flag, the bucket is "none".
+//
+// Different combinations may share a bucket; n should be comfortably larger
+// than the number of cohorts running at the same time. It panics if n is
+// not positive.
+func FeatureFlagBucket(flags []string, n int) string {
+	if n < 1 {
+		panic("FeatureFlagBucket needs a positive number of buckets")
+	}
+	if len(flags) == 0 {
+		return noneLabelValue
+	}
+	sorted := append([]string(nil), flags...)
+	sort.Strings(sorted)
+	h := fnv.New32a()
+	for i, f := range sorted {
+		if i > 0 && f == sorted[i-1] {
+			continue
+		}
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	return strconv.FormatUint(uint64(h.Sum32()%uint32(n)), 10)
+}
+
+// WithFeatureFlagLabel adds a "flag_bucket" label holding the
+// FeatureFlagBucket, out of n, of the flags stored in the request context
+// with ContextWithFeatureFlags. This allows to slice metrics by experiment
+// cohort with a single label of bounded cardinality, rather than one label
+// per flag.
+func WithFeatureFlagLabel(n int) Option {
+	if n < 1 {
+		panic("WithFeatureFlagLabel needs a positive number of buckets")
+	}
+	return func(o *options) {
+		o.addLabel("flag_bucket", func(r *http.Request, _ Delegator) string {
+			return FeatureFlagBucket(FeatureFlagsFromContext(r.Context()), n)
+		})
+	}
+}
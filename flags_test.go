@@ -0,0 +1,68 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestFeatureFlagBucket(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b []string
+	}{
+		{"order", []string{"a", "b"}, []string{"b", "a"}},
+		{"duplicates", []string{"a", "b", "a"}, []string{"a", "b"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if a, b := FeatureFlagBucket(tc.a, 1000), FeatureFlagBucket(tc.b, 1000); a != b {
+				t.Errorf("buckets of %q and %q differ: %s, %s", tc.a, tc.b, a, b)
+			}
+		})
+	}
+	if got := FeatureFlagBucket(nil, 10); got != noneLabelValue {
+		t.Errorf("bucket without flags is %q, want %q", got, noneLabelValue)
+	}
+	if got := FeatureFlagBucket([]string{"a"}, 1); got != "0" {
+		t.Errorf("bucket out of 1 is %q, want \"0\"", got)
+	}
+}
+
+func TestFeatureFlagBucketPanics(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("FeatureFlagBucket with %d buckets did not panic", n)
+				}
+			}()
+			FeatureFlagBucket([]string{"a"}, n)
+		}()
+	}
+}
+
+func TestWithFeatureFlagLabel(t *testing.T) {
+	obs := newDurationVec("flag_bucket")
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithFeatureFlagLabel(16))
+	flagged := []string{"new-checkout", "dark-mode"}
+	for _, flags := range [][]string{nil, flagged, {"dark-mode", "new-checkout"}} {
+		r := httptest.NewRequest("GET", "/", nil)
+		h.ServeHTTP(httptest.NewRecorder(), r.WithContext(ContextWithFeatureFlags(r.Context(), flags...)))
+	}
+	for _, tc := range []struct {
+		bucket string
+		want   uint64
+	}{
+		{noneLabelValue, 1},
+		{FeatureFlagBucket(flagged, 16), 2},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"flag_bucket": tc.bucket}); count != tc.want {
+			t.Errorf("got %d observations in bucket %s, want %d", count, tc.bucket, tc.want)
+		}
+	}
+}
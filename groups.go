@@ -0,0 +1,29 @@
+package prom_mux
+
+import "strings"
+
+// WithRouteGroups replaces the "path" label with the name of the group the
+// path belongs to, to keep cardinality low while still telling functional
+// areas apart. groups maps path prefixes, e.g. "/api/users", to group
+// names; the longest prefix matching the path wins. Paths not matching any
+// prefix are labeled "other".
+func WithRouteGroups(groups map[string]string) Option {
+	prefixes := make([]string, 0, len(groups))
+	for prefix := range groups {
+		prefixes = append(prefixes, prefix)
+	}
+	return func(o *options) {
+		o.addPathRewrite(func(path string) string {
+			best := ""
+			for _, prefix := range prefixes {
+				if len(prefix) > len(best) && strings.HasPrefix(path, prefix) {
+					best = prefix
+				}
+			}
+			if best == "" {
+				return otherLabelValue
+			}
+			return groups[best]
+		})
+	}
+}
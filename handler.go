@@ -0,0 +1,86 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HandlerMetrics are the metrics InstrumentHandler records. A nil field
+// means the metric is not recorded. All vectors are partitioned like for
+// InstrumentHandlerDuration.
+type HandlerMetrics struct {
+	// Duration is observed like by InstrumentHandlerDuration.
+	Duration prometheus.ObserverVec
+	// Counter is incremented like by InstrumentHandlerCounter.
+	Counter *prometheus.CounterVec
+	// RequestSize is observed like by InstrumentHandlerRequestSize.
+	RequestSize prometheus.ObserverVec
+	// ResponseSize is observed like by InstrumentHandlerResponseSize.
+	ResponseSize prometheus.ObserverVec
+	// InFlight is maintained like by InstrumentHandlerInFlight.
+	InFlight prometheus.Gauge
+}
+
+// InstrumentHandler records all metrics set in m for the requests served by
+// next. It is equivalent to nesting the respective InstrumentHandler*
+// functions, but wraps the ResponseWriter and computes the labels only
+// once per request. Options only applying to InstrumentHandlerDuration,
+// like WithClassObservers, apply to m.Duration.
+func InstrumentHandler(m HandlerMetrics, next http.Handler, opts ...Option) http.Handler {
+	mustNotBeNil("InstrumentHandler", "handler", next)
+	o := newOptions(opts)
+	if m.Duration != nil {
+		m.Duration = o.prepareObservers(m.Duration)
+	}
+	if m.Counter != nil {
+		m.Counter = o.curryCounter(m.Counter)
+	}
+	if m.RequestSize != nil {
+		m.RequestSize = o.curry(m.RequestSize)
+	}
+	if m.ResponseSize != nil {
+		m.ResponseSize = o.curry(m.ResponseSize)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if m.InFlight != nil {
+			m.InFlight.Inc()
+			defer m.InFlight.Dec()
+		}
+		now, r := o.start(r)
+		var body *countingBody
+		if m.RequestSize != nil {
+			body = countRequestBody(r)
+		}
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		elapsed := o.now().Sub(now)
+		labels := o.labels(r, d)
+		if m.Duration != nil {
+			o.observe(o.observer(m.Duration, r).With(labels), elapsed.Seconds(), r, d, elapsed)
+		}
+		if m.Counter != nil {
+			o.add(m.Counter.With(labels), 1, r, d, elapsed)
+		}
+		if m.RequestSize != nil {
+			size := float64(requestSize(r, body))
+			o.observe(m.RequestSize.With(labels), size, r, d, elapsed)
+		}
+		if m.ResponseSize != nil {
+			o.observe(m.ResponseSize.With(labels), float64(d.Written()), r, d, elapsed)
+		}
+		o.sampleRawPath(r, labels)
+		o.observeAcceptLatency(r, labels, now)
+		o.countPushes(d, labels)
+		o.countInformational(d, labels)
+		o.emitEvent(r, d, labels, elapsed)
+		o.addSpanEvent(r, d, elapsed)
+		releaseLabels(labels)
+		releaseDelegator(rwd)
+	})
+}
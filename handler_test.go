@@ -0,0 +1,102 @@
+package prom_mux
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name                                                   string
+		duration, counter, requestSize, responseSize, inFlight bool
+	}{
+		{"all", true, true, true, true, true},
+		{"duration", true, false, false, false, false},
+		{"counter and in flight", false, true, false, false, true},
+		{"sizes", false, false, true, true, false},
+		{"none", false, false, false, false, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			duration, counter := newDurationVec(), newCounterVec()
+			requestSize, responseSize := newDurationVec(), newDurationVec()
+			inFlight := prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "test_requests_in_flight",
+				Help: "Test requests in flight.",
+			})
+			// Nil fields must be skipped.
+			var m HandlerMetrics
+			if tc.duration {
+				m.Duration = duration
+			}
+			if tc.counter {
+				m.Counter = counter
+			}
+			if tc.requestSize {
+				m.RequestSize = requestSize
+			}
+			if tc.responseSize {
+				m.ResponseSize = responseSize
+			}
+			if tc.inFlight {
+				m.InFlight = inFlight
+			}
+
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			var inFlightWhileServing float64
+			router := mux.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return InstrumentHandler(m, next, WithClock(clock.Now))
+			})
+			router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+				_, inFlightWhileServing = prommuxtest.CollectObservation(t, inFlight, nil)
+				ioutil.ReadAll(r.Body)
+				clock.Advance(1500 * time.Millisecond)
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, "created!")
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/users/1", strings.NewReader("hello")))
+
+			labels := prometheus.Labels{"code": "201", "method": "post", "path": "/users/{id}"}
+			for _, metric := range []struct {
+				name string
+				c    prometheus.Collector
+				set  bool
+				sum  float64
+			}{
+				{"duration", duration, tc.duration, 1.5},
+				{"counter", counter, tc.counter, 1},
+				{"request size", requestSize, tc.requestSize, 5},
+				{"response size", responseSize, tc.responseSize, 8},
+			} {
+				wantCount, wantSum := uint64(0), 0.0
+				if metric.set {
+					wantCount, wantSum = 1, metric.sum
+				}
+				if count, sum := prommuxtest.CollectObservation(t, metric.c, labels); count != wantCount || sum != wantSum {
+					t.Errorf("%s: got %d observations summing to %v, want %d summing to %v",
+						metric.name, count, sum, wantCount, wantSum)
+				}
+			}
+			wantInFlight := 0.0
+			if tc.inFlight {
+				wantInFlight = 1
+			}
+			if inFlightWhileServing != wantInFlight {
+				t.Errorf("got %v requests in flight while serving, want %v", inFlightWhileServing, wantInFlight)
+			}
+			if _, sum := prommuxtest.CollectObservation(t, inFlight, nil); sum != 0 {
+				t.Errorf("got %v requests in flight after serving, want 0", sum)
+			}
+		})
+	}
+}
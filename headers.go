@@ -0,0 +1,49 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerRequestHeaders observes, for every request reaching
+// next, the number of header fields with count and their approximate size
+// in bytes, the sum of the lengths of all names and values, with size.
+// Both are partitioned like for InstrumentHandlerDuration. Unusually high
+// values point at clients sending abusive header sets.
+//
+// Headers are measured on arrival, as parsed into r.Header. The Host
+// header, which net/http moves to r.Host, is not accounted for.
+func InstrumentHandlerRequestHeaders(
+	count, size prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerRequestHeaders", "count observer", count)
+	mustNotBeNil("InstrumentHandlerRequestHeaders", "size observer", size)
+	mustNotBeNil("InstrumentHandlerRequestHeaders", "handler", next)
+	o := newOptions(opts)
+	count = o.curry(count)
+	size = o.curry(size)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		var n, bytes int
+		for name, values := range r.Header {
+			for _, v := range values {
+				n++
+				bytes += len(name) + len(v)
+			}
+		}
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		elapsed := o.now().Sub(now)
+		labels := o.labels(r, d)
+		o.observe(count.With(labels), float64(n), r, d, elapsed)
+		o.observe(size.With(labels), float64(bytes), r, d, elapsed)
+		releaseLabels(labels)
+		releaseDelegator(rwd)
+	}
+}
@@ -0,0 +1,55 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerRequestHeaders(t *testing.T) {
+	many := http.Header{}
+	for i := 0; i < 100; i++ {
+		many.Add(fmt.Sprintf("X-H%03d", i), "abcd") // 6+4 bytes each
+	}
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		count  float64
+		size   float64
+	}{
+		{"none", http.Header{}, 0, 0},
+		{"one", http.Header{"Accept": {"*/*"}}, 1, 9},
+		{"repeated", http.Header{"Cookie": {"a=1", "b=22"}}, 2, 6 + 3 + 6 + 4},
+		{"many", many, 100, 1000},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			count, size := newDurationVec(), newDurationVec()
+			h := InstrumentHandlerRequestHeaders(count, size, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Headers added by the handler don't count.
+				r.Header.Set("X-Added", "by handler")
+			}))
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header = tc.header
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			for _, obs := range []struct {
+				name string
+				vec  prometheus.Collector
+				want float64
+			}{
+				{"count", count, tc.count},
+				{"size", size, tc.size},
+			} {
+				n, sum := prommuxtest.CollectObservation(t, obs.vec, prometheus.Labels{"code": "200"})
+				if n != 1 || sum != obs.want {
+					t.Errorf("got %d %s observations summing to %v, want 1 of %v", n, obs.name, sum, obs.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package prom_mux
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// failingHijackRecorder is a ResponseRecorder whose connection can't be
+// hijacked.
+type failingHijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingHijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, errors.New("not hijackable")
+}
+
+func TestHijackedCode(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		w      http.ResponseWriter
+		status int // written before hijacking, if not 0
+		want   string
+	}{
+		{"hijacked", hijackRecorder{httptest.NewRecorder()}, 0, hijackedCode},
+		{"switching protocols", hijackRecorder{httptest.NewRecorder()}, http.StatusSwitchingProtocols, "101"},
+		{"hijack failed", failingHijackRecorder{httptest.NewRecorder()}, 0, "200"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+					conn.Close()
+				}
+			}))
+			h.ServeHTTP(tc.w, httptest.NewRequest("GET", "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.want}); count != 1 {
+				t.Errorf("got %d observations with code %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+func TestHijackedCodeServer(t *testing.T) {
+	obs := newDurationVec()
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		rw.Flush()
+	}))
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	<-done
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("got status %d, want 101", res.StatusCode)
+	}
+	for _, tc := range []struct {
+		code string
+		want uint64
+	}{
+		{hijackedCode, 1},
+		{"200", 0},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.code}); count != tc.want {
+			t.Errorf("got %d observations with code %q, want %d", count, tc.code, tc.want)
+		}
+	}
+}
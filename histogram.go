@@ -0,0 +1,114 @@
+package prom_mux
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// durationName returns name with the "_seconds" unit suffix the
+// instruments' observations call for, or an error if name carries another
+// unit of time.
+func durationName(name string) (string, error) {
+	if name == "" {
+		return "http_request_duration_seconds", nil
+	}
+	if strings.HasSuffix(name, "_seconds") {
+		return name, nil
+	}
+	for _, unit := range []string{"_milliseconds", "_ms", "_microseconds", "_us", "_nanoseconds", "_ns", "_minutes"} {
+		if strings.HasSuffix(name, unit) {
+			return "", fmt.Errorf(
+				"metric name %q has unit %q, but durations are observed in seconds", name, unit[1:],
+			)
+		}
+	}
+	return name + "_seconds", nil
+}
+
+// NewRequestDurationHistogram creates a HistogramVec partitioned by "code",
+// "method" and "path", as InstrumentHandlerDuration expects by default,
+// registers it with reg and returns it. If reg is nil, the prometheus
+// default registerer is used. Empty fields of opts are filled in: the name
+// defaults to "http_request_duration_seconds", and the buckets to WebBuckets.
+// Services answering in microseconds should pass FastBuckets instead.
+//
+// Durations are observed in seconds, so the name gets the "_seconds" suffix
+// appended if it lacks it. A name ending in another unit of time, like
+// "_ms", is an error, as is failing to register the histogram.
+func NewRequestDurationHistogram(
+	reg prometheus.Registerer, opts prometheus.HistogramOpts,
+) (prometheus.ObserverVec, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	name, err := durationName(opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	opts.Name = name
+	if opts.Help == "" {
+		opts.Help = "Duration of HTTP requests."
+	}
+	if opts.Buckets == nil {
+		opts.Buckets = WebBuckets
+	}
+	obs := prometheus.NewHistogramVec(opts, []string{"code", "method", "path"})
+	if err := reg.Register(obs); err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
+
+// GetOrRegisterRequestDuration is like NewRequestDurationHistogram, but if
+// an identical histogram, e.g. one created by an earlier call with the same
+// opts, is already registered with reg, it returns that one instead of an
+// error. This suits tests and plugins setting up the same handlers several
+// times. A collector of the same name with a different help text, different
+// labels or of another type is still an error.
+func GetOrRegisterRequestDuration(
+	reg prometheus.Registerer, opts prometheus.HistogramOpts,
+) (prometheus.ObserverVec, error) {
+	obs, err := NewRequestDurationHistogram(reg, opts)
+	are, ok := err.(prometheus.AlreadyRegisteredError)
+	if !ok {
+		return obs, err
+	}
+	existing, ok := are.ExistingCollector.(prometheus.ObserverVec)
+	if !ok {
+		return nil, fmt.Errorf(
+			"already registered collector is a %T, not an ObserverVec", are.ExistingCollector,
+		)
+	}
+	return existing, nil
+}
+
+// NewRequestDurationSummary is like NewRequestDurationHistogram, but creates
+// a SummaryVec. opts, including Objectives and MaxAge, is passed on as is
+// apart from the defaults for the name and help. Without Objectives, the
+// summary only tracks the count and sum.
+//
+// Unlike histogram buckets, the quantiles of summaries from several
+// instances can't be aggregated into a meaningful quantile; prefer a
+// histogram unless every instance is looked at on its own.
+func NewRequestDurationSummary(
+	reg prometheus.Registerer, opts prometheus.SummaryOpts,
+) (prometheus.ObserverVec, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	name, err := durationName(opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	opts.Name = name
+	if opts.Help == "" {
+		opts.Help = "Duration of HTTP requests."
+	}
+	obs := prometheus.NewSummaryVec(opts, []string{"code", "method", "path"})
+	if err := reg.Register(obs); err != nil {
+		return nil, err
+	}
+	return obs, nil
+}
@@ -0,0 +1,141 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestNewRequestDurationSummary(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	obs, err := NewRequestDurationSummary(reg, prometheus.SummaryOpts{
+		Name:       "api_request_duration",
+		Objectives: map[float64]float64{0.5: 0.05, 0.99: 0.001},
+		MaxAge:     time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithClock(clock.Now)))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		clock.Advance(250 * time.Millisecond)
+		if mux.Vars(r)["id"] == "0" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	for _, target := range []string{"/users/1", "/users/2", "/users/0"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	// The summary is observed with the same labels as a histogram.
+	for _, tc := range []struct {
+		code  string
+		count uint64
+		sum   float64
+	}{
+		{"200", 2, 0.5},
+		{"404", 1, 0.25},
+	} {
+		labels := prometheus.Labels{"code": tc.code, "method": "get", "path": "/users/{id}"}
+		count, sum := prommuxtest.CollectObservation(t, obs, labels)
+		if count != tc.count || !approx(sum, tc.sum) {
+			t.Errorf("got %d observations summing to %v for %v, want %d of %v", count, sum, labels, tc.count, tc.sum)
+		}
+	}
+
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || mfs[0].GetName() != "api_request_duration_seconds" {
+		t.Errorf("registered %v, want a single api_request_duration_seconds", mfs)
+	}
+}
+
+func TestNewRequestDurationSummaryErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts prometheus.SummaryOpts
+	}{
+		{"unit", prometheus.SummaryOpts{Name: "request_duration_ms"}},
+		{"registered twice", prometheus.SummaryOpts{Name: "request_duration"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(prometheus.NewSummaryVec(prometheus.SummaryOpts{
+				Name: "request_duration_seconds",
+				Help: "Duration of HTTP requests.",
+			}, []string{"code", "method", "path"}))
+			if _, err := NewRequestDurationSummary(reg, tc.opts); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
+
+func TestGetOrRegisterRequestDuration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	opts := prometheus.HistogramOpts{Name: "plugin_request_duration_seconds"}
+	// Plugins setting up the same handler concurrently all observe into
+	// the one registered histogram.
+	const plugins = 10
+	errs := make(chan error, plugins)
+	for i := 0; i < plugins; i++ {
+		go func() {
+			obs, err := GetOrRegisterRequestDuration(reg, opts)
+			if err == nil {
+				InstrumentHandlerDuration(obs, http.NotFoundHandler()).
+					ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < plugins; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	obs, err := GetOrRegisterRequestDuration(reg, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "404"}); count != plugins {
+		t.Errorf("got %d observations, want %d", count, plugins)
+	}
+}
+
+func TestGetOrRegisterRequestDurationErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		existing prometheus.Collector
+	}{
+		{"other help", prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "http_request_duration_seconds",
+			Help: "Something else.",
+		}, []string{"code", "method", "path"})},
+		{"other labels", prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "http_request_duration_seconds",
+			Help: "Duration of HTTP requests.",
+		}, []string{"code", "path"})},
+		{"other type", prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_duration_seconds",
+			Help: "Duration of HTTP requests.",
+		}, []string{"code", "method", "path"})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(tc.existing)
+			if _, err := GetOrRegisterRequestDuration(reg, prometheus.HistogramOpts{}); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
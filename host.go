@@ -0,0 +1,41 @@
+package prom_mux
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithHostLabel adds a "host" label holding the host the request was sent
+// to, taken from r.Host, lowercased and without the port. If allowed hosts
+// are given, all others are labeled "other". The Host header is chosen by
+// the client, so without an allowlist anyone can create new series; only
+// leave it out if something in front of the server rejects unknown hosts.
+func WithHostLabel(allowed ...string) Option {
+	var set stringSet
+	if len(allowed) > 0 {
+		hosts := make([]string, len(allowed))
+		for i, h := range allowed {
+			hosts[i] = strings.ToLower(h)
+		}
+		set = newStringSet(hosts)
+	}
+	return func(o *options) {
+		o.addLabel("host", func(r *http.Request, _ Delegator) string {
+			host := requestHost(r)
+			if set == nil {
+				return host
+			}
+			return set.get(host)
+		})
+	}
+}
+
+// requestHost returns r.Host lowercased and without the port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
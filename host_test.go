@@ -0,0 +1,42 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithHostLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		allowed []string
+		host    string
+		want    string
+	}{
+		{"plain", nil, "example.com", "example.com"},
+		{"port", nil, "example.com:8080", "example.com"},
+		{"uppercase", nil, "Example.COM:443", "example.com"},
+		{"ipv6 port", nil, "[::1]:8080", "::1"},
+		{"allowed", []string{"example.com"}, "example.com:8080", "example.com"},
+		{"allowed case insensitive", []string{"Example.com"}, "EXAMPLE.com", "example.com"},
+		{"not allowed", []string{"example.com"}, "evil.example", otherLabelValue},
+		{"subdomain not allowed", []string{"example.com"}, "api.example.com", otherLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("host")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+				WithHostLabel(tc.allowed...))
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Host = tc.host
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"host": tc.want}); count != 1 {
+				t.Errorf("got %d observations with host %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
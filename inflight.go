@@ -0,0 +1,142 @@
+package prom_mux
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InFlightTracker is a collector exposing, as a gauge, the age in seconds
+// of the oldest request currently served by the handlers instrumented with
+// InstrumentHandlerInFlightAge. Unlike an in-flight count, it reveals
+// handlers that hang: the gauge keeps growing for as long as the stuck
+// request doesn't complete. It is 0 while no request is in flight.
+type InFlightTracker struct {
+	desc *prometheus.Desc
+
+	mtx     sync.Mutex
+	lastID  uint64
+	started map[uint64]time.Time
+}
+
+// NewInFlightTracker creates an InFlightTracker. It has to be registered to
+// be exported.
+func NewInFlightTracker(opts prometheus.GaugeOpts) *InFlightTracker {
+	return &InFlightTracker{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(opts.Namespace, opts.Subsystem, opts.Name),
+			opts.Help, nil, opts.ConstLabels,
+		),
+		started: make(map[uint64]time.Time),
+	}
+}
+
+func (t *InFlightTracker) start(now time.Time) uint64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.lastID++
+	t.started[t.lastID] = now
+	return t.lastID
+}
+
+func (t *InFlightTracker) done(id uint64) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	delete(t.started, id)
+}
+
+// inFlight returns the number of requests in flight.
+func (t *InFlightTracker) inFlight() int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return len(t.started)
+}
+
+// oldest returns the start time of the oldest request in flight.
+func (t *InFlightTracker) oldest() (time.Time, bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	var oldest time.Time
+	for _, s := range t.started {
+		if oldest.IsZero() || s.Before(oldest) {
+			oldest = s
+		}
+	}
+	return oldest, !oldest.IsZero()
+}
+
+// Describe implements prometheus.Collector.
+func (t *InFlightTracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- t.desc
+}
+
+// Collect implements prometheus.Collector.
+func (t *InFlightTracker) Collect(ch chan<- prometheus.Metric) {
+	var age float64
+	if oldest, ok := t.oldest(); ok {
+		age = time.Since(oldest).Seconds()
+	}
+	ch <- prometheus.MustNewConstMetric(t.desc, prometheus.GaugeValue, age)
+}
+
+// InstrumentHandlerInFlightAge tracks every request served by next in t
+// until next returns, or panics.
+func InstrumentHandlerInFlightAge(
+	t *InFlightTracker, next http.Handler,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerInFlightAge", "tracker", t)
+	mustNotBeNil("InstrumentHandlerInFlightAge", "handler", next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := t.start(time.Now())
+		defer t.done(id)
+		next.ServeHTTP(w, r)
+	}
+}
+
+// InstrumentHandlerInFlight sets g to the number of requests currently
+// served by next. Like in promhttp, g is a plain Gauge: the count is per
+// process, not per route. It is decremented even if next panics.
+func InstrumentHandlerInFlight(g prometheus.Gauge, next http.Handler) http.Handler {
+	mustNotBeNil("InstrumentHandlerInFlight", "gauge", g)
+	mustNotBeNil("InstrumentHandlerInFlight", "handler", next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Inc()
+		defer g.Dec()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// InstrumentHandlerInFlightVec is like InstrumentHandlerInFlight, but counts
+// the requests per route: g is partitioned by "path" and "method" (see
+// WithLabelNames and WithoutMethodLabel). Other labels, including those
+// added by options, are not supported, as they are only known once the
+// handler returned.
+//
+// The labels are taken when the request enters the instrument, so it has
+// to run after routing. Wrapping the handlers of the routes or using it
+// like Middleware with router.Use does that; wrapping the whole router
+// counts every request as unmatched unless WithRouterForMatching is used.
+func InstrumentHandlerInFlightVec(
+	g *prometheus.GaugeVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerInFlightVec", "gauge", g)
+	mustNotBeNil("InstrumentHandlerInFlightVec", "handler", next)
+	o := newOptions(opts)
+	g = o.curryGauge(g, o.methodLabel, o.pathLabelName())
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		labels := prometheus.Labels{o.pathLabelName(): o.pathLabelValue(r)}
+		if o.methodLabel != "" {
+			labels[o.methodLabel] = o.method(r)
+		}
+		gauge := g.With(labels)
+		gauge.Inc()
+		defer gauge.Dec()
+		next.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,95 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerInFlightVec(t *testing.T) {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "test_requests_in_flight",
+		Help: "Test requests in flight.",
+	}, []string{"method", "path", "version"})
+	b := prommuxtest.NewBarrier()
+	h := InstrumentHandlerInFlightVec(g, b.Handler(nil), WithBuildInfoLabel("1.2.3"))
+
+	done := make(chan *httptest.ResponseRecorder, 3)
+	for _, method := range []string{"GET", "GET", "POST"} {
+		res := prommuxtest.Serve(h, httptest.NewRequest(method, "/", nil))
+		go func() { done <- <-res }()
+	}
+	b.WaitHeld(3)
+	for _, tc := range []struct {
+		method string
+		want   float64
+	}{
+		{"get", 2},
+		{"post", 1},
+	} {
+		labels := prometheus.Labels{"method": tc.method, "version": "1.2.3"}
+		if _, v := prommuxtest.CollectObservation(t, g, labels); v != tc.want {
+			t.Errorf("in-flight gauge with labels %v is %v, want %v", labels, v, tc.want)
+		}
+	}
+	b.ReleaseAll()
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+	if _, v := prommuxtest.CollectObservation(t, g, prometheus.Labels{"method": "get"}); v != 0 {
+		t.Errorf("in-flight gauge is %v after all requests returned, want 0", v)
+	}
+}
+
+func TestInstrumentHandlerInFlightVecMissingConstLabel(t *testing.T) {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "test_requests_in_flight",
+		Help: "Test requests in flight.",
+	}, []string{"method", "path"})
+	defer func() {
+		if recover() == nil {
+			t.Error("currying a missing constant label did not panic")
+		}
+	}()
+	InstrumentHandlerInFlightVec(g, http.NotFoundHandler(), WithBuildInfoLabel("1.2.3"))
+}
+
+func TestInstrumentHandlerInFlightAge(t *testing.T) {
+	tracker := NewInFlightTracker(prometheus.GaugeOpts{
+		Name: "test_oldest_request_age_seconds",
+		Help: "Test age of the oldest request in flight.",
+	})
+	b := prommuxtest.NewBarrier()
+	h := InstrumentHandlerInFlightAge(tracker, b.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic(http.ErrAbortHandler)
+		}
+	})))
+
+	if _, age := prommuxtest.CollectObservation(t, tracker, nil); age != 0 {
+		t.Errorf("age is %v without requests, want 0", age)
+	}
+	res := prommuxtest.Serve(h, httptest.NewRequest("GET", "/", nil))
+	b.WaitHeld(1)
+	const held = 20 * time.Millisecond
+	time.Sleep(held)
+	if _, age := prommuxtest.CollectObservation(t, tracker, nil); age < held.Seconds() {
+		t.Errorf("age is %v while a request is held for %v", age, held)
+	}
+	b.ReleaseAll()
+	<-res
+
+	// A panicking handler is not tracked forever.
+	func() {
+		defer func() { recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	}()
+	if _, age := prommuxtest.CollectObservation(t, tracker, nil); age != 0 {
+		t.Errorf("age is %v after all requests returned, want 0", age)
+	}
+}
@@ -0,0 +1,31 @@
+package prom_mux
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// WithInformationalCounter makes InstrumentHandlerDuration and
+// InstrumentHandler add the number of informational (1xx) responses, like
+// 103 Early Hints, a handler sent before its final response to counter,
+// partitioned like the instrument's observer. The code label holds the
+// final status, as for the observation. Requests without an informational
+// response are not counted, and neither are 101 Switching Protocols, which
+// are final.
+func WithInformationalCounter(counter *prometheus.CounterVec) Option {
+	return func(o *options) {
+		o.informationalCounter = counter
+	}
+}
+
+func (o *options) countInformational(d Delegator, labels prometheus.Labels) {
+	if o.informationalCounter == nil {
+		return
+	}
+	i, ok := d.(interface{ informationalResponses() int })
+	if !ok || i.informationalResponses() == 0 {
+		return
+	}
+	o.informationalCounter.With(labels).Add(float64(i.informationalResponses()))
+}
+
+func (r *responseWriterDelegator) informationalResponses() int {
+	return r.informational
+}
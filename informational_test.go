@@ -0,0 +1,47 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithInformationalCounter(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		codes []int
+		code  string
+		want  uint64
+	}{
+		{"early hints", []int{http.StatusEarlyHints, http.StatusOK}, "200", 1},
+		{"several", []int{http.StatusEarlyHints, http.StatusEarlyHints, http.StatusNotFound}, "404", 2},
+		{"switching protocols", []int{http.StatusSwitchingProtocols}, "101", 0},
+		{"final only", []int{http.StatusCreated}, "201", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs, informational := newDurationVec(), newCounterVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				for _, code := range tc.codes {
+					if code == http.StatusEarlyHints {
+						w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+					}
+					w.WriteHeader(code)
+				}
+			}), WithInformationalCounter(informational))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			labels := prometheus.Labels{"code": tc.code}
+			if count, _ := prommuxtest.CollectObservation(t, informational, labels); count != tc.want {
+				t.Errorf("got %d informational responses, want %d", count, tc.want)
+			}
+			// The duration is observed with the final status.
+			if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+				t.Errorf("got %d observations with code %s, want 1", count, tc.code)
+			}
+		})
+	}
+}
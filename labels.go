@@ -0,0 +1,290 @@
+package prom_mux
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const magicString = "zZgWfBxLqvG8kc8IMv3POi2Bb0tZI3vAnBx+gBaFi9FyPzB/CzKUer1yufDa"
+
+// labelNames returns the sorted names of the variable labels of c that
+// still need a value, i.e. that are neither constant nor curried. c has to
+// be a single metric vector as accepted by the InstrumentHandler*
+// functions.
+func labelNames(c prometheus.Collector) ([]string, error) {
+	names, err := orderedLabelNames(c)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// orderedLabelNames is like labelNames, but returns the names in the order
+// the values have to be passed to WithLabelValues.
+//
+// Descriptors can't have their dimensionality queried, so this uses the
+// same trick as promhttp: create a const metric with as many label values
+// as it takes and look at the labels it ends up with. Each value is made
+// unique to tell the position of its label.
+func orderedLabelNames(c prometheus.Collector) ([]string, error) {
+	var (
+		desc *prometheus.Desc
+		m    prometheus.Metric
+		pm   dto.Metric
+		lvs  []string
+	)
+
+	// Collectors like the one returned by DualObserver describe more than
+	// one metric, so all descriptions have to be drained.
+	descc := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descc)
+		close(descc)
+	}()
+	n := 0
+	for d := range descc {
+		desc = d
+		n++
+	}
+	switch {
+	case n == 0:
+		return nil, errors.New("no description provided by collector")
+	case n > 1:
+		return nil, errors.New("more than one description provided by collector")
+	}
+
+	for err := errors.New("dummy"); err != nil; lvs = append(lvs, magicString+strconv.Itoa(len(lvs))) {
+		if len(lvs) > 64 {
+			return nil, fmt.Errorf("unable to determine labels of %s", desc)
+		}
+		m, err = prometheus.NewConstMetric(desc, prometheus.UntypedValue, 0, lvs...)
+	}
+	if err := m.Write(&pm); err != nil {
+		return nil, fmt.Errorf("error checking metric for labels: %v", err)
+	}
+
+	positions := make([]string, len(lvs))
+	for _, label := range pm.Label {
+		name, value := label.GetName(), label.GetValue()
+		if !strings.HasPrefix(value, magicString) || isLabelCurried(c, name) {
+			continue
+		}
+		i, err := strconv.Atoi(strings.TrimPrefix(value, magicString))
+		if err != nil || i >= len(positions) {
+			continue
+		}
+		positions[i] = name
+	}
+	var names []string
+	for _, name := range positions {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// isLabelCurried reports whether label has already been curried in c. It
+// tries to curry it again, which fails if it has been.
+func isLabelCurried(c prometheus.Collector, label string) bool {
+	var err error
+	switch v := c.(type) {
+	case prometheus.ObserverVec:
+		_, err = v.CurryWith(prometheus.Labels{label: "dummy"})
+	case *prometheus.CounterVec:
+		_, err = v.CurryWith(prometheus.Labels{label: "dummy"})
+	case *prometheus.GaugeVec:
+		_, err = v.CurryWith(prometheus.Labels{label: "dummy"})
+	default:
+		return false
+	}
+	return err != nil
+}
+
+// sameLabels reports whether a and b hold the same label names.
+func sameLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WithLabelNames renames the labels holding the status code, the method
+// and the path, "code", "method" and "path" by default, e.g. to
+// "http_status", "http_method" and "route" to follow an organization's
+// naming conventions. Empty names keep the current name.
+//
+// Like with the default names, the vectors passed to the instrument are
+// checked when it is created, and it panics if they aren't partitioned by
+// the renamed labels.
+func WithLabelNames(code, method, path string) Option {
+	return func(o *options) {
+		if code != "" {
+			o.codeLabel = code
+		}
+		if method != "" {
+			o.methodLabel = method
+		}
+		if path != "" {
+			o.pathLabel = path
+		}
+		o.customLabels = true
+	}
+}
+
+// checkLabelNames panics if c is not partitioned by exactly names, plus the
+// constant labels of o, so that a vector missing a label, or having one no
+// option sets, fails when the instrument is created rather than with the
+// first request it serves. Labels curried by the caller don't count. Labels
+// not in names are allowed if WithExtraLabels is used, as its names are
+// only known once a request is served. Vectors whose labels can't be
+// determined, like those returned by DualObserver, are not checked.
+func (o *options) checkLabelNames(c prometheus.Collector, names ...string) {
+	have, err := labelNames(c)
+	if err != nil {
+		return
+	}
+	for _, name := range names {
+		if name == "" {
+			// A label dropped with WithoutMethodLabel and friends.
+			continue
+		}
+		if !containsString(have, name) {
+			panic(fmt.Sprintf(
+				"vector with labels %q has no label %q, expected labels %q",
+				have, name, nonEmpty(names),
+			))
+		}
+	}
+	if len(o.labelsFuncs) > 0 {
+		return
+	}
+	for _, name := range have {
+		if _, ok := o.constLabels[name]; ok || containsString(names, name) {
+			continue
+		}
+		panic(fmt.Sprintf(
+			"vector with labels %q has unexpected label %q, expected labels %q",
+			have, name, nonEmpty(names),
+		))
+	}
+}
+
+// instrumentLabelNames returns the names of the labels set by o.labels,
+// followed by extra, except for the ones set by WithExtraLabels, which are
+// only known once a request is served.
+func (o *options) instrumentLabelNames(extra ...string) []string {
+	names := []string{o.codeLabel, o.methodLabel, o.pathLabelName()}
+	for _, l := range o.extraLabels {
+		names = append(names, l.name)
+	}
+	return append(names, extra...)
+}
+
+// curry curries obs with the constant labels of o, after checking it is
+// partitioned by the labels o sets and the extra ones set by the instrument.
+func (o *options) curry(obs prometheus.ObserverVec, extra ...string) prometheus.ObserverVec {
+	o.checkLabelNames(obs, o.instrumentLabelNames(extra...)...)
+	return o.curryConst(obs)
+}
+
+// curryConst curries obs with the constant labels of o.
+func (o *options) curryConst(obs prometheus.ObserverVec) prometheus.ObserverVec {
+	if len(o.constLabels) == 0 {
+		return obs
+	}
+	curried, err := obs.CurryWith(o.constLabels)
+	if err != nil {
+		panic(fmt.Sprintf("currying labels %v: %v", o.constLabels, err))
+	}
+	return curried
+}
+
+// curryCounter is like curry for counters.
+func (o *options) curryCounter(counter *prometheus.CounterVec, extra ...string) *prometheus.CounterVec {
+	o.checkLabelNames(counter, o.instrumentLabelNames(extra...)...)
+	if len(o.constLabels) == 0 {
+		return counter
+	}
+	curried, err := counter.CurryWith(o.constLabels)
+	if err != nil {
+		panic(fmt.Sprintf("currying labels %v: %v", o.constLabels, err))
+	}
+	return curried
+}
+
+// curryGauge is like curryCounter for gauges, except that g is only checked
+// for names: gauges are set before the labels of the response are known.
+func (o *options) curryGauge(g *prometheus.GaugeVec, names ...string) *prometheus.GaugeVec {
+	o.checkLabelNames(g, names...)
+	if len(o.constLabels) == 0 {
+		return g
+	}
+	curried, err := g.CurryWith(o.constLabels)
+	if err != nil {
+		panic(fmt.Sprintf("currying labels %v: %v", o.constLabels, err))
+	}
+	return curried
+}
+
+func nonEmpty(names []string) []string {
+	var ne []string
+	for _, name := range names {
+		if name != "" {
+			ne = append(ne, name)
+		}
+	}
+	return ne
+}
+
+// WithoutMethodLabel drops the method label, for endpoints only ever
+// serving one method, where it would just multiply the number of series.
+// The vectors passed to the instrument must then be partitioned without it.
+func WithoutMethodLabel() Option {
+	return func(o *options) {
+		o.methodLabel = ""
+		o.customLabels = true
+	}
+}
+
+// WithoutCodeLabel drops the code label, for handlers that always answer
+// with the same status, e.g. internal health proxies. The status is still
+// tracked for everything else, like WithEventSink. Combined with
+// WithoutMethodLabel, the vectors are partitioned by the path alone (plus
+// the labels added by other options).
+func WithoutCodeLabel() Option {
+	return func(o *options) {
+		o.codeLabel = ""
+		o.customLabels = true
+	}
+}
+
+// WithExtraLabels adds the labels returned by fn for each request, e.g. a
+// "tenant" label taken from a header. They never replace the code, method
+// and path labels, nor those added by other options: names already in use
+// are ignored. If fn returns nil, no label is added.
+//
+// As with all labels, the vectors have to be partitioned by exactly the
+// resulting set, so fn should return the same names for every request.
+func WithExtraLabels(fn func(*http.Request) prometheus.Labels) Option {
+	return func(o *options) {
+		o.labelsFuncs = append(o.labelsFuncs, fn)
+	}
+}
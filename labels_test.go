@@ -0,0 +1,176 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestCheckLabelNames(t *testing.T) {
+	tenant := WithExtraLabels(func(r *http.Request) prometheus.Labels {
+		return prometheus.Labels{"tenant": r.Header.Get("X-Tenant")}
+	})
+	for _, tc := range []struct {
+		name   string
+		obs    func() prometheus.ObserverVec
+		opts   []Option
+		panics bool
+	}{
+		{"default", func() prometheus.ObserverVec { return newDurationVec() }, nil, false},
+		{"missing", func() prometheus.ObserverVec {
+			return prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "d", Help: "d"}, []string{"code", "path"})
+		}, nil, true},
+		{"extra", func() prometheus.ObserverVec { return newDurationVec("tenant") }, nil, true},
+		{"extra curried", func() prometheus.ObserverVec {
+			return newDurationVec("tenant").MustCurryWith(prometheus.Labels{"tenant": "a"})
+		}, nil, false},
+		{"extra constant", func() prometheus.ObserverVec { return newDurationVec("tenant") },
+			[]Option{WithConstLabels(prometheus.Labels{"tenant": "a"})}, false},
+		{"extra set by option", func() prometheus.ObserverVec { return newDurationVec("source") },
+			[]Option{WithQueryParamLabel("source", "ios")}, false},
+		{"extra set by WithExtraLabels", func() prometheus.ObserverVec { return newDurationVec("tenant") },
+			[]Option{tenant}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := tc.obs()
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != tc.panics {
+						t.Fatalf("got panic %v, want panic: %v", p, tc.panics)
+					}
+				}()
+				InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), tc.opts...).
+					ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			}()
+			if tc.panics {
+				return
+			}
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"}); count != 1 {
+				t.Errorf("got %d observations, want 1", count)
+			}
+		})
+	}
+}
+
+func TestWithExtraLabels(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		extra  []string
+		fn     func(*http.Request) prometheus.Labels
+		opts   []Option
+		labels prometheus.Labels
+	}{
+		{"header", []string{"tenant"}, func(r *http.Request) prometheus.Labels {
+			return prometheus.Labels{"tenant": r.Header.Get("X-Tenant")}
+		}, nil, prometheus.Labels{"code": "200", "tenant": "acme"}},
+		{"nil", nil, func(*http.Request) prometheus.Labels {
+			return nil
+		}, nil, prometheus.Labels{"code": "200", "method": "get", "path": defaultUnmatchedPath}},
+		{"no clobbering", []string{"tenant"}, func(*http.Request) prometheus.Labels {
+			return prometheus.Labels{"code": "999", "method": "fake", "path": "/fake", "tenant": "acme"}
+		}, nil, prometheus.Labels{"code": "200", "method": "get", "path": defaultUnmatchedPath, "tenant": "acme"}},
+		{"no clobbering options", []string{"source", "tenant"}, func(*http.Request) prometheus.Labels {
+			return prometheus.Labels{"source": "fake", "tenant": "acme"}
+		}, []Option{WithQueryParamLabel("source", "ios")}, prometheus.Labels{"source": "ios", "tenant": "acme"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec(tc.extra...)
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+				append(tc.opts, WithExtraLabels(tc.fn))...)
+			r := httptest.NewRequest("GET", "/?source=ios", nil)
+			r.Header.Set("X-Tenant", "acme")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, tc.labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, tc.labels)
+			}
+		})
+	}
+}
+
+// newVec returns a histogram partitioned by labels.
+func newVec(labels ...string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_request_duration_seconds",
+		Help: "Test request durations.",
+	}, labels)
+}
+
+func TestWithLabelNames(t *testing.T) {
+	obs := newVec("http_status", "http_method", "route")
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithLabelNames("http_status", "http_method", "route")))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/users/1", nil))
+
+	labels := prometheus.Labels{"http_status": "404", "http_method": "delete", "route": "/users/{id}"}
+	if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+		t.Errorf("got %d observations with labels %v, want 1", count, labels)
+	}
+}
+
+func TestWithLabelNamesRejectsDefaultNames(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		code, method, path string
+	}{
+		{"all", "http_status", "http_method", "route"},
+		{"path only", "", "", "route"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("vector with the default names did not panic")
+				}
+			}()
+			InstrumentHandlerDuration(newDurationVec(), http.NotFoundHandler(),
+				WithLabelNames(tc.code, tc.method, tc.path))
+		})
+	}
+}
+
+func TestWithoutLabels(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		labels []string
+		opts   []Option
+		want   prometheus.Labels
+	}{
+		{"WithoutMethodLabel", []string{"code", "path"}, []Option{WithoutMethodLabel()},
+			prometheus.Labels{"code": "201", "path": "/users/{id}"}},
+		{"WithoutCodeLabel", []string{"method", "path"}, []Option{WithoutCodeLabel()},
+			prometheus.Labels{"method": "put", "path": "/users/{id}"}},
+		{"both", []string{"path"}, []Option{WithoutMethodLabel(), WithoutCodeLabel()},
+			prometheus.Labels{"path": "/users/{id}"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newVec(tc.labels...)
+			router := mux.NewRouter()
+			router.Use(Middleware(obs, tc.opts...))
+			router.HandleFunc("/users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			})
+			for i := 0; i < 2; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", "/users/1", nil))
+			}
+			if count, _ := prommuxtest.CollectObservation(t, obs, tc.want); count != 2 {
+				t.Errorf("got %d observations with labels %v, want 2", count, tc.want)
+			}
+
+			// A vector still partitioned by the dropped label is rejected.
+			defer func() {
+				if recover() == nil {
+					t.Error("vector with the dropped label did not panic")
+				}
+			}()
+			Middleware(newDurationVec(), tc.opts...)
+		})
+	}
+}
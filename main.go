@@ -1,15 +1,26 @@
+// Package prom_mux instruments HTTP handlers with Prometheus metrics, labeled
+// by default with the path template of the gorilla/mux route they serve.
+//
+// The instruments and the ResponseWriter delegator work with any router:
+// WithPathExtractor replaces the route lookup of mux.go for the path label.
+// The package still depends on gorilla/mux though, both for that default
+// and for the helpers built around mux.Router, like Middleware,
+// NewMetricsRouter, WithRouterForMatching and InstrumentMethodNotAllowed, so
+// it is always part of the build.
 package prom_mux
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -20,13 +31,35 @@ const (
 	pusher
 )
 
-type delegator interface {
-	http.ResponseWriter
-
+// ResponseInfo tells what has been sent through a ResponseWriter returned by
+// WrapResponseWriter so far.
+type ResponseInfo interface {
+	// Status returns the status code of the response, or 0 if none has
+	// been set yet.
 	Status() int
 	Written() int64
 }
 
+// Delegator is the http.ResponseWriter passed to instrumented handlers. It
+// keeps track of the response status and the number of bytes written.
+type Delegator interface {
+	http.ResponseWriter
+	ResponseInfo
+}
+
+// WrapResponseWriter returns a ResponseWriter delegating to w, as used by
+// the instruments of this package, and a ResponseInfo about what was sent
+// through it. It implements the same optional interfaces, like
+// http.Flusher, as w. If observeWriteHeader is not nil, it is called with
+// the status code once the final header is written. This lets middlewares,
+// e.g. for logging, track responses without wrapping w another time.
+func WrapResponseWriter(
+	w http.ResponseWriter, observeWriteHeader func(int),
+) (http.ResponseWriter, ResponseInfo) {
+	d := newDelegator(w, observeWriteHeader)
+	return d, d
+}
+
 type responseWriterDelegator struct {
 	http.ResponseWriter
 
@@ -34,17 +67,49 @@ type responseWriterDelegator struct {
 	written            int64
 	wroteHeader        bool
 	observeWriteHeader func(int)
+
+	// timedOut is set once a Write failed because an enclosing
+	// http.TimeoutHandler gave up on the request.
+	timedOut bool
+	// hijacked is set once the handler took over the connection.
+	hijacked bool
+	// pushed counts the successful HTTP/2 server pushes.
+	pushed int
+	// informational counts the 1xx responses sent before the final one.
+	informational int
+	// implicitHeader is set if the header was written by the first Write,
+	// Flush or ReadFrom rather than by the handler calling WriteHeader.
+	implicitHeader bool
+	// flushed is set once the handler flushed the response.
+	flushed bool
 }
 
 func (r *responseWriterDelegator) Status() int {
+	if r.timedOut {
+		// The client got the 503 written by http.TimeoutHandler, not
+		// whatever the handler tried to send.
+		return http.StatusServiceUnavailable
+	}
 	return r.status
 }
 
+// Written returns the number of body bytes written so far. Bytes copied with
+// io.Copy are counted whatever the wrapped ResponseWriter supports: if it
+// implements io.ReaderFrom, so does the delegator (see newDelegator) and
+// readerFromDelegator counts what ReadFrom reports; if it doesn't, neither
+// does the delegator, so io.Copy falls back to Write, which counts as well.
 func (r *responseWriterDelegator) Written() int64 {
 	return r.written
 }
 
 func (r *responseWriterDelegator) WriteHeader(code int) {
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols && !r.wroteHeader {
+		// An informational response like 103 Early Hints, followed by
+		// the final one. Since Go 1.19, net/http sends them as such.
+		r.informational++
+		r.ResponseWriter.WriteHeader(code)
+		return
+	}
 	if r.observeWriteHeader != nil && !r.wroteHeader {
 		// Only call observeWriteHeader for the 1st time. It's a bug if
 		// WriteHeader is called more than once, but we want to protect
@@ -57,14 +122,24 @@ func (r *responseWriterDelegator) WriteHeader(code int) {
 	r.ResponseWriter.WriteHeader(code)
 }
 
+// writeImplicitHeader writes a 200 header on behalf of a handler that
+// started writing the body without calling WriteHeader.
+func (r *responseWriterDelegator) writeImplicitHeader() {
+	r.WriteHeader(http.StatusOK)
+	r.implicitHeader = true
+}
+
 func (r *responseWriterDelegator) Write(b []byte) (int, error) {
 	// If applicable, call WriteHeader here so that observeWriteHeader is
 	// handled appropriately.
 	if !r.wroteHeader {
-		r.WriteHeader(http.StatusOK)
+		r.writeImplicitHeader()
 	}
 	n, err := r.ResponseWriter.Write(b)
 	r.written += int64(n)
+	if err == http.ErrHandlerTimeout {
+		r.timedOut = true
+	}
 	return n, err
 }
 
@@ -77,65 +152,78 @@ func (d flusherDelegator) Flush() {
 	// If applicable, call WriteHeader here so that observeWriteHeader is
 	// handled appropriately.
 	if !d.wroteHeader {
-		d.WriteHeader(http.StatusOK)
+		d.writeImplicitHeader()
 	}
+	d.flushed = true
 	d.ResponseWriter.(http.Flusher).Flush()
 }
 func (d hijackerDelegator) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	return d.ResponseWriter.(http.Hijacker).Hijack()
+	conn, rw, err := d.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		d.hijacked = true
+	}
+	return conn, rw, err
 }
 func (d readerFromDelegator) ReadFrom(re io.Reader) (int64, error) {
 	// If applicable, call WriteHeader here so that observeWriteHeader is
 	// handled appropriately.
 	if !d.wroteHeader {
-		d.WriteHeader(http.StatusOK)
+		d.writeImplicitHeader()
 	}
 	n, err := d.ResponseWriter.(io.ReaderFrom).ReadFrom(re)
 	d.written += n
 	return n, err
 }
+
+// Push initiates a server push. The pushed response is served by a separate
+// run of the handler, which is instrumented on its own; neither its bytes
+// nor its header count for the request that pushed it. See WithPushCounter.
 func (d pusherDelegator) Push(target string, opts *http.PushOptions) error {
-	return d.ResponseWriter.(http.Pusher).Push(target, opts)
+	err := d.ResponseWriter.(http.Pusher).Push(target, opts)
+	if err == nil {
+		d.pushed++
+	}
+	return err
 }
 
-var pickDelegator = make([]func(*responseWriterDelegator) delegator, 32)
+var pickDelegator = make([]func(*responseWriterDelegator) Delegator, 32)
 
 func init() {
 	// TODO(beorn7): Code generation would help here.
-	pickDelegator[0] = func(d *responseWriterDelegator) delegator {
+	pickDelegator[0] = func(d *responseWriterDelegator) Delegator {
 		return d
 	}
-	pickDelegator[flusher] = func(d *responseWriterDelegator) delegator {
+	pickDelegator[flusher] = func(d *responseWriterDelegator) Delegator {
 		return flusherDelegator{d}
 	}
-	pickDelegator[hijacker] = func(d *responseWriterDelegator) delegator {
+	pickDelegator[hijacker] = func(d *responseWriterDelegator) Delegator {
 		return hijackerDelegator{d}
 	}
-	pickDelegator[hijacker+flusher] = func(d *responseWriterDelegator) delegator {
+	pickDelegator[hijacker+flusher] = func(d *responseWriterDelegator) Delegator {
 		return struct {
 			*responseWriterDelegator
 			http.Hijacker
 			http.Flusher
 		}{d, hijackerDelegator{d}, flusherDelegator{d}}
 	}
-	pickDelegator[readerFrom] = func(d *responseWriterDelegator) delegator {
+	pickDelegator[readerFrom] = func(d *responseWriterDelegator) Delegator {
 		return readerFromDelegator{d}
 	}
-	pickDelegator[readerFrom+flusher] = func(d *responseWriterDelegator) delegator { // 10
+	pickDelegator[readerFrom+flusher] = func(d *responseWriterDelegator) Delegator { // 10
 		return struct {
 			*responseWriterDelegator
 			io.ReaderFrom
 			http.Flusher
 		}{d, readerFromDelegator{d}, flusherDelegator{d}}
 	}
-	pickDelegator[readerFrom+hijacker] = func(d *responseWriterDelegator) delegator { // 12
+	pickDelegator[readerFrom+hijacker] = func(d *responseWriterDelegator) Delegator { // 12
 		return struct {
 			*responseWriterDelegator
 			io.ReaderFrom
 			http.Hijacker
 		}{d, readerFromDelegator{d}, hijackerDelegator{d}}
 	}
-	pickDelegator[readerFrom+hijacker+flusher] = func(d *responseWriterDelegator) delegator { // 14
+	pickDelegator[readerFrom+hijacker+flusher] = func(d *responseWriterDelegator) Delegator { // 14
 		return struct {
 			*responseWriterDelegator
 			io.ReaderFrom
@@ -143,24 +231,24 @@ func init() {
 			http.Flusher
 		}{d, readerFromDelegator{d}, hijackerDelegator{d}, flusherDelegator{d}}
 	}
-	pickDelegator[pusher] = func(d *responseWriterDelegator) delegator { // 16
+	pickDelegator[pusher] = func(d *responseWriterDelegator) Delegator { // 16
 		return pusherDelegator{d}
 	}
-	pickDelegator[pusher+flusher] = func(d *responseWriterDelegator) delegator { // 18
+	pickDelegator[pusher+flusher] = func(d *responseWriterDelegator) Delegator { // 18
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
 			http.Flusher
 		}{d, pusherDelegator{d}, flusherDelegator{d}}
 	}
-	pickDelegator[pusher+hijacker] = func(d *responseWriterDelegator) delegator { // 20
+	pickDelegator[pusher+hijacker] = func(d *responseWriterDelegator) Delegator { // 20
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
 			http.Hijacker
 		}{d, pusherDelegator{d}, hijackerDelegator{d}}
 	}
-	pickDelegator[pusher+hijacker+flusher] = func(d *responseWriterDelegator) delegator { // 22
+	pickDelegator[pusher+hijacker+flusher] = func(d *responseWriterDelegator) Delegator { // 22
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
@@ -168,14 +256,14 @@ func init() {
 			http.Flusher
 		}{d, pusherDelegator{d}, hijackerDelegator{d}, flusherDelegator{d}}
 	}
-	pickDelegator[pusher+readerFrom] = func(d *responseWriterDelegator) delegator { // 24
+	pickDelegator[pusher+readerFrom] = func(d *responseWriterDelegator) Delegator { // 24
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
 			io.ReaderFrom
 		}{d, pusherDelegator{d}, readerFromDelegator{d}}
 	}
-	pickDelegator[pusher+readerFrom+flusher] = func(d *responseWriterDelegator) delegator { // 26
+	pickDelegator[pusher+readerFrom+flusher] = func(d *responseWriterDelegator) Delegator { // 26
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
@@ -183,7 +271,7 @@ func init() {
 			http.Flusher
 		}{d, pusherDelegator{d}, readerFromDelegator{d}, flusherDelegator{d}}
 	}
-	pickDelegator[pusher+readerFrom+hijacker] = func(d *responseWriterDelegator) delegator { // 28
+	pickDelegator[pusher+readerFrom+hijacker] = func(d *responseWriterDelegator) Delegator { // 28
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
@@ -191,7 +279,7 @@ func init() {
 			http.Hijacker
 		}{d, pusherDelegator{d}, readerFromDelegator{d}, hijackerDelegator{d}}
 	}
-	pickDelegator[pusher+readerFrom+hijacker+flusher] = func(d *responseWriterDelegator) delegator { // 30
+	pickDelegator[pusher+readerFrom+hijacker+flusher] = func(d *responseWriterDelegator) Delegator { // 30
 		return struct {
 			*responseWriterDelegator
 			http.Pusher
@@ -202,12 +290,43 @@ func init() {
 	}
 }
 
-func newDelegator(w http.ResponseWriter, observeWriteHeaderFunc func(int)) delegator {
-	d := &responseWriterDelegator{
+func newDelegator(w http.ResponseWriter, observeWriteHeaderFunc func(int)) Delegator {
+	return wrapDelegator(&responseWriterDelegator{
 		ResponseWriter:     w,
 		observeWriteHeader: observeWriteHeaderFunc,
+	})
+}
+
+var delegatorPool = sync.Pool{
+	New: func() interface{} { return new(responseWriterDelegator) },
+}
+
+// acquireDelegator is like newDelegator, but takes the responseWriterDelegator
+// from a pool, saving an allocation per request on the hot path. The caller
+// must hand it back with releaseDelegator once neither the wrapped handler
+// nor the instrument touch the returned Delegator anymore.
+func acquireDelegator(w http.ResponseWriter) (Delegator, *responseWriterDelegator) {
+	d := delegatorPool.Get().(*responseWriterDelegator)
+	d.ResponseWriter = w
+	return wrapDelegator(d), d
+}
+
+// releaseDelegator resets d, so that nothing of the request it served leaks
+// into the next one, and returns it to the pool. Delegators whose
+// connection was hijacked are left to the garbage collector, as the handler
+// may have handed them to code still running.
+func releaseDelegator(d *responseWriterDelegator) {
+	if d.hijacked {
+		return
 	}
+	*d = responseWriterDelegator{}
+	delegatorPool.Put(d)
+}
 
+// wrapDelegator returns d wrapped so that it implements the same optional
+// interfaces as the http.ResponseWriter it delegates to.
+func wrapDelegator(d *responseWriterDelegator) Delegator {
+	w := d.ResponseWriter
 	id := 0
 	if _, ok := w.(http.Flusher); ok {
 		id += flusher
@@ -243,11 +362,40 @@ func sanitizeMethod(m string) string {
 		return "options"
 	case "NOTIFY", "notify":
 		return "notify"
+	case "PATCH", "patch":
+		return "patch"
+	case "TRACE", "trace":
+		return "trace"
 	default:
 		return strings.ToLower(m)
 	}
 }
 
+// hijackedCode is the code label of requests whose handler hijacked the
+// connection without setting a status, e.g. for a WebSocket upgrade. They
+// never got a regular response, so reporting 200 would be misleading.
+const hijackedCode = "hijacked"
+
+// wasHijacked reports whether the handler hijacked the connection of d
+// without writing a header first.
+func wasHijacked(d Delegator) bool {
+	h, ok := d.(interface{ hijackedOnly() bool })
+	return ok && h.hijackedOnly()
+}
+
+func (r *responseWriterDelegator) hijackedOnly() bool {
+	return r.hijacked && !r.wroteHeader
+}
+
+// effectiveCode returns the status the client got for a handler that set
+// status s, following the same rule as sanitizeCode.
+func effectiveCode(s int) int {
+	if s == 0 {
+		return http.StatusOK
+	}
+	return s
+}
+
 // If the wrapped http.Handler has not set a status code, i.e. the value is
 // currently 0, santizeCode will return 200, for consistency with behavior in
 // the stdlib.
@@ -257,6 +405,10 @@ func sanitizeCode(s int) string {
 		return "100"
 	case 101:
 		return "101"
+	case 102:
+		return "102"
+	case 103:
+		return "103"
 
 	case 200, 0:
 		return "200"
@@ -272,6 +424,12 @@ func sanitizeCode(s int) string {
 		return "205"
 	case 206:
 		return "206"
+	case 207:
+		return "207"
+	case 208:
+		return "208"
+	case 226:
+		return "226"
 
 	case 300:
 		return "300"
@@ -279,12 +437,18 @@ func sanitizeCode(s int) string {
 		return "301"
 	case 302:
 		return "302"
+	case 303:
+		return "303"
 	case 304:
 		return "304"
 	case 305:
 		return "305"
+	case 306:
+		return "306"
 	case 307:
 		return "307"
+	case 308:
+		return "308"
 
 	case 400:
 		return "400"
@@ -337,13 +501,39 @@ func sanitizeCode(s int) string {
 		return "504"
 	case 505:
 		return "505"
+	case 506:
+		return "506"
+	case 507:
+		return "507"
+	case 508:
+		return "508"
+	case 510:
+		return "510"
 
 	case 428:
 		return "428"
 	case 429:
 		return "429"
+	case 421:
+		return "421"
+	case 422:
+		return "422"
+	case 423:
+		return "423"
+	case 424:
+		return "424"
+	case 425:
+		return "425"
+	case 426:
+		return "426"
 	case 431:
 		return "431"
+	case 451:
+		return "451"
+	// Not a standard code, but used by nginx and others for requests the
+	// client closed before a response was sent.
+	case 499:
+		return "499"
 	case 511:
 		return "511"
 
@@ -352,30 +542,68 @@ func sanitizeCode(s int) string {
 	}
 }
 
-func metricsPath(r *http.Request) string {
-	route := mux.CurrentRoute(r)
-	if route == nil {
-		return r.RequestURI
+// mustNotBeNil panics if v, the argument called name of the function fn, is
+// nil, including a nil pointer or func stored in an interface. A missing
+// observer or handler is a programming error; reporting it when the handler
+// is built beats a nil dereference on the first request.
+func mustNotBeNil(fn, name string, v interface{}) {
+	if v != nil {
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Func, reflect.Map, reflect.Chan,
+			reflect.Interface, reflect.Slice:
+			if !rv.IsNil() {
+				return
+			}
+		default:
+			return
+		}
 	}
-	path, err := route.GetPathTemplate()
-	if err != nil {
-		return r.RequestURI
-	}
-	return path
+	panic(fmt.Sprintf("%s: %s must not be nil", fn, name))
 }
 
 func InstrumentHandlerDuration(
-	obs prometheus.ObserverVec, next http.Handler,
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerDuration", "observer", obs)
+	mustNotBeNil("InstrumentHandlerDuration", "handler", next)
+	o := newOptions(opts)
+	obs = o.prepareObservers(obs)
+	return instrumentDuration(obs, next, o)
+}
+
+func instrumentDuration(
+	obs prometheus.ObserverVec, next http.Handler, o *options,
 ) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		now := time.Now()
-		d := newDelegator(w, nil)
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d, rwd := acquireDelegator(w)
 		next.ServeHTTP(d, r)
 
-		obs.With(prometheus.Labels{
-			"code":   sanitizeCode(d.Status()),
-			"method": sanitizeMethod(r.Method),
-			"path":   metricsPath(r),
-		}).Observe(time.Since(now).Seconds())
+		o.recordDuration(obs, r, d, now)
+		// Not deferred: if next panics, the delegator is simply left to
+		// the garbage collector.
+		releaseDelegator(rwd)
 	}
 }
+
+// recordDuration observes the duration of r, which started at now, and
+// feeds everything else configured in o that takes the request's labels.
+func (o *options) recordDuration(
+	obs prometheus.ObserverVec, r *http.Request, d Delegator, now time.Time,
+) {
+	elapsed := o.now().Sub(now)
+	labels := o.labels(r, d)
+	o.observe(o.observer(obs, r).With(labels), elapsed.Seconds(), r, d, elapsed)
+	o.sampleRawPath(r, labels)
+	o.observeAcceptLatency(r, labels, now)
+	o.countPushes(d, labels)
+	o.countInformational(d, labels)
+	o.emitEvent(r, d, labels, elapsed)
+	o.addSpanEvent(r, d, elapsed)
+	releaseLabels(labels)
+}
@@ -0,0 +1,360 @@
+package prom_mux
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// newDurationVec returns a histogram partitioned like the instruments
+// expect by default, plus the labels in extra.
+func newDurationVec(extra ...string) *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_request_duration_seconds",
+		Help: "Test request durations.",
+	}, append([]string{"code", "method", "path"}, extra...))
+}
+
+// newCounterVec is like newDurationVec for counters.
+func newCounterVec(extra ...string) *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_requests_total",
+		Help: "Test requests.",
+	}, append([]string{"code", "method", "path"}, extra...))
+}
+
+// hijackRecorder is a ResponseRecorder whose connection can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (r hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, peer := net.Pipe()
+	peer.Close()
+	return conn, bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), nil
+}
+
+// readerFromRecorder is a ResponseRecorder implementing io.ReaderFrom, like
+// the ResponseWriter of net/http does.
+type readerFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int
+}
+
+func (r *readerFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom++
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+// onlyReader hides all methods of its Reader but Read, so that io.Copy can't
+// use io.WriterTo instead of the ReaderFrom of the destination.
+type onlyReader struct{ io.Reader }
+
+func TestWrittenCountsCopy(t *testing.T) {
+	const body = "hello, world"
+	for _, tc := range []struct {
+		name       string
+		w          http.ResponseWriter
+		readerFrom bool
+	}{
+		{"Write", httptest.NewRecorder(), false},
+		{"ReadFrom", &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newDelegator(tc.w, nil)
+			if _, ok := d.(io.ReaderFrom); ok != tc.readerFrom {
+				t.Fatalf("delegator implements io.ReaderFrom: %v, want %v", ok, tc.readerFrom)
+			}
+			n, err := io.Copy(d, onlyReader{strings.NewReader(body)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(body)) {
+				t.Fatalf("copied %d bytes, want %d", n, len(body))
+			}
+			if got := d.Written(); got != int64(len(body)) {
+				t.Errorf("Written() = %d, want %d", got, len(body))
+			}
+			if got := d.Status(); got != http.StatusOK {
+				t.Errorf("Status() = %d, want %d", got, http.StatusOK)
+			}
+			if rfr, ok := tc.w.(*readerFromRecorder); ok && rfr.readFrom != 1 {
+				t.Errorf("ReadFrom called %d times, want 1", rfr.readFrom)
+			}
+		})
+	}
+}
+
+// listedCodes returns the codes sanitizeCode is expected to return without
+// allocating: those known to net/http plus 499.
+func listedCodes() []int {
+	codes := []int{499}
+	for c := 100; c < 600; c++ {
+		if http.StatusText(c) != "" {
+			codes = append(codes, c)
+		}
+	}
+	return codes
+}
+
+func TestAddedCodesObserved(t *testing.T) {
+	for _, tc := range []struct {
+		code int
+		want string
+	}{
+		{http.StatusIMUsed, "226"},
+		{http.StatusPermanentRedirect, "308"},
+		{http.StatusMisdirectedRequest, "421"},
+		{http.StatusUnavailableForLegalReasons, "451"},
+		{499, "499"},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tc.code)
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.want}); count != 1 {
+				t.Errorf("got %d observations with code %s, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeCode(t *testing.T) {
+	if got := sanitizeCode(0); got != "200" {
+		t.Errorf("sanitizeCode(0) = %q, want \"200\"", got)
+	}
+	for c := -1; c < 1000; c++ {
+		if c == 0 {
+			continue
+		}
+		if got, want := sanitizeCode(c), strconv.Itoa(c); got != want {
+			t.Errorf("sanitizeCode(%d) = %q, want %q", c, got, want)
+		}
+	}
+}
+
+func TestSanitizeCodeAllocs(t *testing.T) {
+	for _, c := range listedCodes() {
+		allocs := testing.AllocsPerRun(10, func() {
+			sanitizeCode(c)
+		})
+		if allocs != 0 {
+			t.Errorf("sanitizeCode(%d) allocates %v times, want 0", c, allocs)
+		}
+	}
+}
+
+func TestMethodObserved(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		want   string
+	}{
+		{"GET", "get"},
+		{"post", "post"},
+		{"PATCH", "patch"},
+		{"patch", "patch"},
+		{"TRACE", "trace"},
+		{"PROPFIND", "propfind"},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			if got := sanitizeMethod(tc.method); got != tc.want {
+				t.Errorf("sanitizeMethod(%q) = %q, want %q", tc.method, got, tc.want)
+			}
+
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, "/", nil))
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"method": tc.want}); count != 1 {
+				t.Errorf("got %d observations with method %s, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+func BenchmarkSanitizeCode(b *testing.B) {
+	codes := listedCodes()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sanitizeCode(codes[i%len(codes)])
+	}
+}
+
+func TestPooledDelegatorsStartClean(t *testing.T) {
+	obs := newDurationVec("streamed", "status_origin")
+	var fresh []bool
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d := w.(Delegator)
+		f, _ := d.(interface{ wasFlushed() bool })
+		fresh = append(fresh, d.Status() == 0 && d.Written() == 0 && !f.wasFlushed() &&
+			!wasHijacked(d))
+		switch r.URL.Path {
+		case "/hijack":
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.Close()
+		case "/flush":
+			w.Write([]byte("chunk"))
+			w.(http.Flusher).Flush()
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+	}), WithStreamedLabel(), WithStatusOriginLabel())
+
+	// A hijacked request, whose delegator is not pooled, then a flushed
+	// and two plain ones, each likely to get the delegator of the previous
+	// one.
+	for _, tc := range []struct {
+		path string
+		w    http.ResponseWriter
+	}{
+		{"/hijack", hijackRecorder{httptest.NewRecorder()}},
+		{"/flush", httptest.NewRecorder()},
+		{"/plain", httptest.NewRecorder()},
+		{"/plain", hijackRecorder{httptest.NewRecorder()}},
+	} {
+		h.ServeHTTP(tc.w, httptest.NewRequest("GET", tc.path, nil))
+	}
+	for i, ok := range fresh {
+		if !ok {
+			t.Errorf("request %d got a delegator with state left over", i)
+		}
+	}
+	for _, labels := range []prometheus.Labels{
+		{"code": hijackedCode, "streamed": "false", "status_origin": "none"},
+		{"code": "200", "streamed": "true", "status_origin": "implicit"},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+			t.Errorf("got %d observations with labels %v, want 1", count, labels)
+		}
+	}
+	labels := prometheus.Labels{"code": "201", "streamed": "false", "status_origin": "explicit"}
+	if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 2 {
+		t.Errorf("got %d observations with labels %v, want 2", count, labels)
+	}
+}
+
+func TestReleaseDelegatorResets(t *testing.T) {
+	d := &responseWriterDelegator{
+		ResponseWriter:     httptest.NewRecorder(),
+		status:             http.StatusTeapot,
+		written:            42,
+		wroteHeader:        true,
+		observeWriteHeader: func(int) {},
+		timedOut:           true,
+		pushed:             1,
+		informational:      1,
+		implicitHeader:     true,
+		flushed:            true,
+	}
+	releaseDelegator(d)
+	if !reflect.DeepEqual(*d, responseWriterDelegator{}) {
+		t.Errorf("released delegator not reset: %+v", *d)
+	}
+
+	hijacked := &responseWriterDelegator{status: http.StatusSwitchingProtocols, hijacked: true}
+	releaseDelegator(hijacked)
+	if hijacked.status != http.StatusSwitchingProtocols {
+		t.Error("hijacked delegator was reset and pooled")
+	}
+}
+
+func BenchmarkDelegator(b *testing.B) {
+	w := httptest.NewRecorder()
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			d, rwd := acquireDelegator(w)
+			d.WriteHeader(http.StatusOK)
+			releaseDelegator(rwd)
+		}
+	})
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			d := newDelegator(w, nil)
+			d.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+func BenchmarkInstrumentHandlerDuration(b *testing.B) {
+	h := InstrumentHandlerDuration(newDurationVec(), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		},
+	))
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(w, r)
+	}
+}
+
+// hijackReaderFromRecorder implements all optional interfaces the
+// delegator passes on.
+type hijackReaderFromRecorder struct {
+	*readerFromRecorder
+}
+
+func (r hijackReaderFromRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return hijackRecorder{r.ResponseRecorder}.Hijack()
+}
+
+func TestWrapResponseWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name                          string
+		w                             http.ResponseWriter
+		flusher, hijacker, readerFrom bool
+	}{
+		{"recorder", httptest.NewRecorder(), true, false, false},
+		{"hijacker", hijackRecorder{httptest.NewRecorder()}, true, true, false},
+		{"reader from", &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}, true, false, true},
+		{"all", hijackReaderFromRecorder{&readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}}, true, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var observed []int
+			w, info := WrapResponseWriter(tc.w, func(status int) { observed = append(observed, status) })
+			if _, ok := w.(http.Flusher); ok != tc.flusher {
+				t.Errorf("implements http.Flusher: %v, want %v", ok, tc.flusher)
+			}
+			if _, ok := w.(http.Hijacker); ok != tc.hijacker {
+				t.Errorf("implements http.Hijacker: %v, want %v", ok, tc.hijacker)
+			}
+			if _, ok := w.(io.ReaderFrom); ok != tc.readerFrom {
+				t.Errorf("implements io.ReaderFrom: %v, want %v", ok, tc.readerFrom)
+			}
+
+			if info.Status() != 0 || info.Written() != 0 {
+				t.Errorf("got status %d and %d bytes before writing, want none", info.Status(), info.Written())
+			}
+			w.WriteHeader(http.StatusAccepted)
+			io.WriteString(w, "hello")
+			if got := info.Status(); got != http.StatusAccepted {
+				t.Errorf("Status() = %d, want %d", got, http.StatusAccepted)
+			}
+			if got := info.Written(); got != 5 {
+				t.Errorf("Written() = %d, want 5", got)
+			}
+			if !reflect.DeepEqual(observed, []int{http.StatusAccepted}) {
+				t.Errorf("observed statuses %v, want [%d]", observed, http.StatusAccepted)
+			}
+		})
+	}
+}
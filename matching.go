@@ -0,0 +1,46 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// WithRouterForMatching lets the instrument resolve the path template with
+// router when it wraps the router, or otherwise runs before routing, and so
+// finds no current route on the request. Instead of labeling the request as
+// unmatched, it then matches the request against router itself, which
+// also resolves requests rejected with 405 Method Not Allowed to the route
+// whose path matched.
+//
+// Matching is only done for requests without a current route, once per
+// instrument and request, after the handler has returned. It costs about as
+// much as the routing done by router itself.
+func WithRouterForMatching(router *mux.Router) Option {
+	return func(o *options) {
+		o.matchPath = func(r *http.Request) (string, bool) {
+			return matchPath(router, r)
+		}
+	}
+}
+
+// matchPath returns the path template of the route of router matching r.
+func matchPath(router *mux.Router, r *http.Request) (string, bool) {
+	var (
+		match mux.RouteMatch
+		route *mux.Route
+	)
+	if router.Match(r, &match) && match.Route != nil {
+		route = match.Route
+	} else if match.MatchErr == mux.ErrMethodMismatch {
+		route = methodMismatchRoute(router, r)
+	}
+	if route == nil {
+		return "", false
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,46 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRouterForMatching(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{id}", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+	for _, tc := range []struct {
+		name     string
+		method   string
+		target   string
+		matching bool
+		code     string
+		path     string
+	}{
+		{"matched", "GET", "/users/1", true, "200", "/users/{id}"},
+		{"method not allowed", "POST", "/users/1", true, "405", "/users/{id}"},
+		{"not found", "GET", "/nope", true, "404", defaultUnmatchedPath},
+		{"without router", "GET", "/users/1", false, "200", defaultUnmatchedPath},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			var opts []Option
+			if tc.matching {
+				opts = append(opts, WithRouterForMatching(router))
+			}
+			// The instrument wraps the router, so it runs before routing.
+			h := InstrumentHandlerDuration(obs, router, opts...)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.target, nil))
+
+			count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.code, "path": tc.path})
+			if count != 1 {
+				t.Errorf("got %d observations for %s %s, want 1", count, tc.code, tc.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package prom_mux
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var errStopWalk = errors.New("stop walking")
+
+// InstrumentMethodNotAllowed makes router observe requests it rejects with
+// 405 Method Not Allowed with obs, labeled with code "405" and the path
+// template of the route whose path matched but whose methods did not.
+//
+// gorilla/mux answers such requests without a current route and without
+// running the middlewares registered with Use, so neither a router
+// middleware nor an instrument wrapping the router can tell which route was
+// hit. InstrumentMethodNotAllowed sets router.MethodNotAllowedHandler to an
+// instrumented handler that looks the route up itself. A handler already
+// set as MethodNotAllowedHandler is kept and wrapped; otherwise a plain 405
+// is written like mux does by default. Call it after all routes have been
+// added, and do not replace MethodNotAllowedHandler afterwards.
+func InstrumentMethodNotAllowed(
+	router *mux.Router, obs prometheus.ObserverVec, opts ...Option,
+) {
+	mustNotBeNil("InstrumentMethodNotAllowed", "router", router)
+	mustNotBeNil("InstrumentMethodNotAllowed", "observer", obs)
+	next := router.MethodNotAllowedHandler
+	if next == nil {
+		next = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		})
+	}
+	opts = append(opts[:len(opts):len(opts)], func(o *options) {
+		o.pathFunc = func(r *http.Request) string {
+			if route := methodMismatchRoute(router, r); route != nil {
+				if path, err := route.GetPathTemplate(); err == nil {
+					return path
+				}
+			}
+			return metricsPath(r, o.unmatchedPath)
+		}
+	})
+	router.MethodNotAllowedHandler = InstrumentHandlerDuration(obs, next, opts...)
+}
+
+// methodMismatchRoute returns the first route of router that would have
+// matched r if r had been sent with one of the methods the route accepts.
+func methodMismatchRoute(router *mux.Router, r *http.Request) *mux.Route {
+	var found *mux.Route
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if route.GetHandler() == nil {
+			// Routes holding a subrouter; their leaf routes are
+			// visited as well.
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil || len(methods) == 0 {
+			return nil
+		}
+		for _, m := range methods {
+			if m == r.Method {
+				return nil
+			}
+		}
+		req := r.Clone(r.Context())
+		req.Method = methods[0]
+		if route.Match(req, &mux.RouteMatch{}) {
+			found = route
+			return errStopWalk
+		}
+		return nil
+	})
+	return found
+}
@@ -0,0 +1,32 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware returns a mux.MiddlewareFunc instrumenting every route of the
+// router it is used with like InstrumentHandlerDuration, e.g.
+//
+//	router.Use(prom_mux.Middleware(obs))
+//
+// mux only runs middlewares for matched routes, so the path label is the
+// route's template. If the returned middleware is used to wrap something
+// else, requests without a current route are labeled "<unmatched>" (see
+// WithUnmatchedPath), unless WithRouterForMatching resolves them. The
+// options are applied once, not per route.
+//
+// Requests mux rejects with 404 or 405 never reach the middleware. Use
+// InstrumentMethodNotAllowed with the same vector to have 405s observed
+// with the template of the route whose path matched.
+func Middleware(obs prometheus.ObserverVec, opts ...Option) mux.MiddlewareFunc {
+	mustNotBeNil("Middleware", "observer", obs)
+	o := newOptions(opts)
+	obs = o.prepareObservers(obs)
+	return func(next http.Handler) http.Handler {
+		mustNotBeNil("Middleware", "handler", next)
+		return instrumentDuration(obs, next, o)
+	}
+}
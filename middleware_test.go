@@ -0,0 +1,109 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestMiddlewareMethodNotAllowed(t *testing.T) {
+	obs := newDurationVec()
+	router := mux.NewRouter()
+	router.Use(Middleware(obs))
+	router.HandleFunc("/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods(http.MethodGet)
+	InstrumentMethodNotAllowed(router, obs)
+
+	for _, tc := range []struct {
+		method string
+		want   int
+		labels prometheus.Labels
+	}{
+		{
+			http.MethodGet, http.StatusNoContent,
+			prometheus.Labels{"code": "204", "method": "get", "path": "/users/{id}"},
+		},
+		{
+			http.MethodPost, http.StatusMethodNotAllowed,
+			prometheus.Labels{"code": "405", "method": "post", "path": "/users/{id}"},
+		},
+	} {
+		t.Run(tc.method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(tc.method, "/users/42", nil))
+			if rec.Code != tc.want {
+				t.Fatalf("got status %d, want %d", rec.Code, tc.want)
+			}
+			if count, _ := prommuxtest.CollectObservation(t, obs, tc.labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, tc.labels)
+			}
+		})
+	}
+	if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{
+		"path": defaultUnmatchedPath,
+	}); count != 0 {
+		t.Errorf("got %d observations of unmatched requests, want 0", count)
+	}
+}
+
+func TestInstrumentMethodNotAllowedKeepsOptions(t *testing.T) {
+	opts := make([]Option, 1, 2)
+	opts[0] = WithClock(prommuxtest.NewClock(time.Unix(0, 0)).Now)
+	InstrumentMethodNotAllowed(mux.NewRouter(), newDurationVec(), opts...)
+	if spare := opts[:2][1]; spare != nil {
+		t.Error("InstrumentMethodNotAllowed wrote into the backing array of the options")
+	}
+}
+
+func TestInstrumentMethodNotAllowed(t *testing.T) {
+	noop := func(http.ResponseWriter, *http.Request) {}
+	for _, tc := range []struct {
+		name   string
+		custom http.Handler
+		target string
+		code   int
+		path   string
+	}{
+		{"default handler", nil, "/users/1", http.StatusMethodNotAllowed, "/users/{id}"},
+		{"custom handler", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		}), "/users/1", http.StatusMethodNotAllowed, "/users/{id}"},
+		{"subrouter", nil, "/api/orders/7", http.StatusMethodNotAllowed, "/api/orders/{id}"},
+		{"not found", nil, "/nope", http.StatusNotFound, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			router.MethodNotAllowedHandler = tc.custom
+			router.HandleFunc("/users/{id}", noop).Methods(http.MethodGet)
+			router.PathPrefix("/api").Subrouter().HandleFunc("/orders/{id}", noop).Methods(http.MethodGet, http.MethodPut)
+			InstrumentMethodNotAllowed(router, obs)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.target, nil))
+			if rec.Code != tc.code {
+				t.Fatalf("got status %d, want %d", rec.Code, tc.code)
+			}
+			if tc.custom != nil && rec.Body.String() != "use GET\n" {
+				t.Errorf("got body %q from the custom handler", rec.Body.String())
+			}
+
+			labels := prometheus.Labels{"code": "405", "method": "post", "path": tc.path}
+			want := uint64(1)
+			if tc.path == "" {
+				// mux answers 404s on its own.
+				labels, want = nil, 0
+			}
+			if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != want {
+				t.Errorf("got %d observations with labels %v, want %d", count, labels, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package prom_mux
+
+// This file holds everything the instruments need to know about gorilla/mux
+// to label requests by default. WithPathExtractor replaces all of it for
+// the path label. The helpers taking or returning mux types live next to
+// what they are about.
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// defaultUnmatchedPath is the path label of requests without a route, unless
+// changed with WithUnmatchedPath.
+const defaultUnmatchedPath = "<unmatched>"
+
+// metricsPath returns the path template of the route r matched, or
+// unmatched if there is none. Falling back to the request URI instead would
+// create a series for every URL scanned by a bot.
+func metricsPath(r *http.Request, unmatched string) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return unmatched
+	}
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return unmatched
+	}
+	return path
+}
+
+func routeMethod(r *http.Request) (string, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+	methods, err := route.GetMethods()
+	if err != nil || len(methods) == 0 {
+		return "", false
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, r.Method) {
+			return m, true
+		}
+	}
+	if len(methods) == 1 {
+		return methods[0], true
+	}
+	return "", false
+}
+
+// hasRoute reports whether r was routed by a mux.Router.
+func hasRoute(r *http.Request) bool {
+	return mux.CurrentRoute(r) != nil
+}
+
+// routeName returns the name of the current route of r, if any.
+func routeName(r *http.Request) (string, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil || route.GetName() == "" {
+		return "", false
+	}
+	return route.GetName(), true
+}
+
+// routeVar returns the value of the route variable called name of r, if any.
+func routeVar(r *http.Request, name string) (string, bool) {
+	v, ok := mux.Vars(r)[name]
+	return v, ok
+}
@@ -0,0 +1,55 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRouteMethod(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		opts   []Option
+		routed string // the method the request is routed with
+		sent   string // the method the handlers see
+		path   string
+		want   string
+	}{
+		{"declared", []Option{WithRouteMethod()}, "GET", "GET", "/read", "get"},
+		{"one of declared", []Option{WithRouteMethod()}, "HEAD", "HEAD", "/read", "head"},
+		{"only declared", []Option{WithRouteMethod()}, "GET", "FETCH", "/get", "get"},
+		{"request", nil, "GET", "FETCH", "/get", "fetch"},
+		{"undeclared", []Option{WithRouteMethod()}, "DELETE", "DELETE", "/any", "delete"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			// Like a middleware rewriting method aliases after
+			// routing.
+			router.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					r.Method = r.Header.Get("X-Method")
+					next.ServeHTTP(w, r)
+				})
+			})
+			router.Use(Middleware(obs, tc.opts...))
+			noop := func(http.ResponseWriter, *http.Request) {}
+			router.HandleFunc("/read", noop).Methods("GET", "HEAD")
+			router.HandleFunc("/get", noop).Methods("GET")
+			router.HandleFunc("/any", noop)
+
+			r := httptest.NewRequest(tc.routed, tc.path, nil)
+			r.Header.Set("X-Method", tc.sent)
+			router.ServeHTTP(httptest.NewRecorder(), r)
+			labels := prometheus.Labels{"method": tc.want, "path": tc.path}
+			if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, labels)
+			}
+		})
+	}
+}
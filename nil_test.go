@@ -0,0 +1,74 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestNilObserver(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("ok")) })
+	for _, tc := range []struct {
+		fn       string
+		labels   []string
+		observes bool
+		new      func(obs *prometheus.HistogramVec) http.Handler
+	}{
+		{"InstrumentHandlerDuration", nil, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerDuration(obs, ok)
+		}},
+		{"InstrumentHandlerDurationFast", nil, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerDurationFast(obs, ok)
+		}},
+		{"InstrumentHandlerResponseSize", nil, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerResponseSize(obs, ok)
+		}},
+		{"InstrumentHandlerRequestSize", nil, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerRequestSize(obs, ok)
+		}},
+		{"InstrumentHandlerTimeToWriteHeader", nil, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerTimeToWriteHeader(obs, ok)
+		}},
+		{"InstrumentHandlerCPUTime", nil, false, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerCPUTime(obs, ok)
+		}},
+		{"InstrumentHandlerPhases", []string{"phase"}, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerPhases(obs, ok)
+		}},
+		{"InstrumentHandlerDBWait", nil, false, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerDBWait(obs, ok)
+		}},
+		{"InstrumentHandlerRetryAfter", nil, false, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerRetryAfter(obs, ok)
+		}},
+		{"InstrumentHandlerRecovery", nil, false, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerRecovery(obs, ok)
+		}},
+		{"InstrumentHandlerAllocations", nil, true, func(obs *prometheus.HistogramVec) http.Handler {
+			return InstrumentHandlerAllocations(obs, ok, func(*http.Request) bool { return true })
+		}},
+	} {
+		t.Run(tc.fn, func(t *testing.T) {
+			func() {
+				want := tc.fn + ": observer must not be nil"
+				defer func() {
+					if got := recover(); got != want {
+						t.Errorf("got panic %v, want %q", got, want)
+					}
+				}()
+				// A nil pointer in a non-nil interface is caught as well.
+				tc.new(nil)
+			}()
+
+			obs := newDurationVec(tc.labels...)
+			tc.new(obs).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"}); tc.observes && count != 1 {
+				t.Errorf("got %d observations with a non-nil observer, want 1", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// standardMethods are the methods WithObserverForMethod asks for a vector.
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// WithObserverForMethod makes InstrumentHandlerDuration observe requests
+// with the vector returned by fn for their method, e.g. a histogram with
+// finer buckets for POST, PUT and DELETE than for GET. If fn returns nil,
+// the vector passed to InstrumentHandlerDuration is used.
+//
+// fn is called once for each standard HTTP method (in upper case) when the
+// instrument is created, not per request. Requests with other methods use
+// the primary vector. All returned vectors must be partitioned by the same
+// labels as the primary one, otherwise InstrumentHandlerDuration panics.
+// A latency class set with WithClassObservers takes precedence.
+func WithObserverForMethod(fn func(method string) prometheus.ObserverVec) Option {
+	return func(o *options) {
+		o.methodObserver = fn
+	}
+}
+
+// prepareObservers curries obs and the alternative observers configured in
+// o with the constant labels and returns the curried obs. It panics if any
+// alternative observer is not partitioned like obs.
+func (o *options) prepareObservers(obs prometheus.ObserverVec) prometheus.ObserverVec {
+	obs = o.curry(obs)
+	if len(o.classObservers) == 0 && o.methodObserver == nil {
+		return obs
+	}
+	want, err := labelNames(obs)
+	if err != nil {
+		panic(err)
+	}
+	prepare := func(vec prometheus.ObserverVec, what string) prometheus.ObserverVec {
+		vec = o.curry(vec)
+		got, err := labelNames(vec)
+		if err != nil {
+			panic(err)
+		}
+		if !sameLabels(want, got) {
+			panic(fmt.Sprintf(
+				"labels %q of observer for %s differ from %q", got, what, want,
+			))
+		}
+		return vec
+	}
+
+	classObservers := make(map[string]prometheus.ObserverVec, len(o.classObservers))
+	for class, vec := range o.classObservers {
+		classObservers[class] = prepare(vec, fmt.Sprintf("class %q", class))
+	}
+	o.classObservers = classObservers
+
+	if o.methodObserver != nil {
+		o.methodObservers = make(map[string]prometheus.ObserverVec)
+		for _, m := range standardMethods {
+			if vec := o.methodObserver(m); vec != nil {
+				o.methodObservers[m] = prepare(vec, "method "+m)
+			}
+		}
+	}
+	return obs
+}
+
+// observer returns the vector to observe r with.
+func (o *options) observer(obs prometheus.ObserverVec, r *http.Request) prometheus.ObserverVec {
+	if len(o.classObservers) > 0 {
+		if class, ok := LatencyClassFromContext(r.Context()); ok {
+			if vec, ok := o.classObservers[class]; ok {
+				return vec
+			}
+		}
+	}
+	if vec, ok := o.methodObservers[r.Method]; ok {
+		return vec
+	}
+	return obs
+}
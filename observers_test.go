@@ -0,0 +1,62 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithObserverForMethod(t *testing.T) {
+	primary, writes := newDurationVec(), newDurationVec()
+	var asked []string
+	h := InstrumentHandlerDuration(primary, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithObserverForMethod(func(method string) prometheus.ObserverVec {
+			asked = append(asked, method)
+			switch method {
+			case http.MethodPost, http.MethodPut, http.MethodDelete:
+				return writes
+			}
+			return nil
+		}))
+	if len(asked) != len(standardMethods) {
+		t.Errorf("asked for vectors for %q, want %q", asked, standardMethods)
+	}
+	for _, method := range []string{"GET", "POST", "PUT", "DELETE", "HEAD", "PURGE"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+	}
+
+	for _, tc := range []struct {
+		method  string
+		primary uint64
+		writes  uint64
+	}{
+		{"get", 1, 0},
+		{"head", 1, 0},
+		{"post", 0, 1},
+		{"put", 0, 1},
+		{"delete", 0, 1},
+		{"purge", 1, 0},
+	} {
+		labels := prometheus.Labels{"method": tc.method}
+		if count, _ := prommuxtest.CollectObservation(t, primary, labels); count != tc.primary {
+			t.Errorf("got %d %s observations in the primary vector, want %d", count, tc.method, tc.primary)
+		}
+		if count, _ := prommuxtest.CollectObservation(t, writes, labels); count != tc.writes {
+			t.Errorf("got %d %s observations in the write vector, want %d", count, tc.method, tc.writes)
+		}
+	}
+}
+
+func TestWithObserverForMethodLabelMismatch(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("observer with different labels did not panic")
+		}
+	}()
+	InstrumentHandlerDuration(newDurationVec(), http.NotFoundHandler(),
+		WithObserverForMethod(func(string) prometheus.ObserverVec { return newDurationVec("extra") }))
+}
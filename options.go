@@ -0,0 +1,340 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures the InstrumentHandler* functions.
+type Option func(*options)
+
+type options struct {
+	now func() time.Time
+
+	codeLabel    string
+	methodLabel  string
+	pathLabel    string
+	customLabels bool
+	statusClass  bool
+	routeMethod  bool
+	methods      stringSet
+	routeHash    bool
+	eventSink    func(Event)
+	cumulative   bool
+	extraLabels  []labelFunc
+	labelsFuncs  []func(*http.Request) prometheus.Labels
+	entryLabels  bool
+	pathFunc     func(*http.Request) string
+	pathRewrites []func(string) string
+	matchPath    func(*http.Request) (string, bool)
+	routeName    bool
+
+	unmatchedPath string
+
+	exemplar         func(context.Context) prometheus.Labels
+	exemplarSampling func(Delegator, time.Duration) bool
+
+	classObservers  map[string]prometheus.ObserverVec
+	methodObserver  func(string) prometheus.ObserverVec
+	methodObservers map[string]prometheus.ObserverVec
+
+	timeoutStatus bool
+	panicResponse bool
+
+	noResponseCode string
+	invalidCode    bool
+
+	spanFromContext func(context.Context) Span
+
+	rawPathCounter *prometheus.CounterVec
+	rawPathRate    float64
+
+	constLabels prometheus.Labels
+
+	clientIPHeader string
+
+	skips []func(*http.Request) bool
+
+	contextHooks []func(context.Context) context.Context
+
+	acceptObserver prometheus.ObserverVec
+	pushCounter    *prometheus.CounterVec
+
+	informationalCounter *prometheus.CounterVec
+}
+
+// labelFunc computes the value of an additional label once the wrapped
+// handler has returned or, for entry labels, when the request enters the
+// instrument.
+type labelFunc struct {
+	name  string
+	value func(r *http.Request, d Delegator) string
+	entry bool
+}
+
+func (o *options) addLabel(name string, value func(*http.Request, Delegator) string) {
+	o.extraLabels = append(o.extraLabels, labelFunc{name: name, value: value})
+}
+
+// addEntryLabel adds a label whose value is taken by start, before the
+// wrapped handler runs.
+func (o *options) addEntryLabel(name string, value func(*http.Request) string) {
+	o.extraLabels = append(o.extraLabels, labelFunc{
+		name: name,
+		value: func(r *http.Request, _ Delegator) string {
+			return value(r)
+		},
+		entry: true,
+	})
+	o.entryLabels = true
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		now:         time.Now,
+		codeLabel:   "code",
+		methodLabel: "method",
+		pathLabel:   "path",
+
+		unmatchedPath: defaultUnmatchedPath,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.acceptObserver != nil {
+		o.acceptObserver = o.curry(o.acceptObserver)
+	}
+	if o.pushCounter != nil {
+		o.pushCounter = o.curryCounter(o.pushCounter)
+	}
+	if o.informationalCounter != nil {
+		o.informationalCounter = o.curryCounter(o.informationalCounter)
+	}
+	if o.rawPathCounter != nil && len(o.constLabels) > 0 {
+		// Not curryCounter: the raw path takes the place of the route
+		// hash, so the counter is partitioned differently.
+		o.rawPathCounter = o.rawPathCounter.MustCurryWith(o.constLabels)
+	}
+	return o
+}
+
+// WithClock makes the instrument use now instead of time.Now to take the
+// time, which allows tests to observe deterministic durations, e.g. with
+// prommuxtest.Clock. Collectors exposing values relative to the time they
+// are scraped, like Apdex, RateEWMA and InFlightTracker, keep following the
+// wall clock.
+func WithClock(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithRouteMethod makes the "method" label use the method declared on the
+// matched mux route instead of the one sent by the client. If the route
+// declares several methods, the one matching the request (case
+// insensitively) is used; routes without a method matcher keep the request
+// method.
+func WithRouteMethod() Option {
+	return func(o *options) {
+		o.routeMethod = true
+	}
+}
+
+// status returns the status of the response to r, as recorded by d or, with
+// WithTimeoutHandlerStatus, as sent by http.TimeoutHandler.
+func (o *options) status(r *http.Request, d Delegator) int {
+	if o.timeoutStatus && r.Context().Err() == context.DeadlineExceeded {
+		return http.StatusServiceUnavailable
+	}
+	return d.Status()
+}
+
+// code returns the value of the code label for the response to r.
+func (o *options) code(r *http.Request, d Delegator) string {
+	status := o.status(r, d)
+	switch {
+	case wasHijacked(d):
+		return hijackedCode
+	case o.invalidCode && invalidStatus(status, d):
+		return invalidCode
+	case o.noResponseCode != "" && status == 0 && d.Written() == 0:
+		return o.noResponseCode
+	case o.statusClass:
+		return statusClass(status)
+	default:
+		return sanitizeCode(status)
+	}
+}
+
+// labelsPool holds label maps to be reused by labels. Vectors don't keep the
+// maps passed to With, so every instrument hands the map back with
+// releaseLabels once it has made its observations.
+var labelsPool = sync.Pool{
+	New: func() interface{} { return make(prometheus.Labels, 8) },
+}
+
+// releaseLabels clears labels, obtained from o.labels, and returns it to the
+// pool. labels must not be used afterwards.
+func releaseLabels(labels prometheus.Labels) {
+	for name := range labels {
+		delete(labels, name)
+	}
+	labelsPool.Put(labels)
+}
+
+func (o *options) labels(r *http.Request, d Delegator) prometheus.Labels {
+	labels := labelsPool.Get().(prometheus.Labels)
+	if o.codeLabel != "" {
+		labels[o.codeLabel] = o.code(r, d)
+	}
+	if o.methodLabel != "" {
+		labels[o.methodLabel] = o.method(r)
+	}
+	labels[o.pathLabelName()] = o.pathLabelValue(r)
+	entryValues, _ := r.Context().Value(entryLabelsKey).(map[string]string)
+	for _, l := range o.extraLabels {
+		if v, ok := entryValues[l.name]; ok && l.entry {
+			labels[l.name] = v
+			continue
+		}
+		labels[l.name] = l.value(r, d)
+	}
+	for _, fn := range o.labelsFuncs {
+		for name, value := range fn(r) {
+			if _, ok := labels[name]; !ok {
+				labels[name] = value
+			}
+		}
+	}
+	return labels
+}
+
+func (o *options) addPathRewrite(rewrite func(string) string) {
+	o.pathRewrites = append(o.pathRewrites, rewrite)
+}
+
+func (o *options) path(r *http.Request) string {
+	path := o.basePath(r)
+	for _, rewrite := range o.pathRewrites {
+		path = rewrite(path)
+	}
+	return path
+}
+
+// skip reports whether r must be passed on to the wrapped handler without
+// being instrumented.
+func (o *options) skip(r *http.Request) bool {
+	for _, skip := range o.skips {
+		if skip(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *options) basePath(r *http.Request) string {
+	if o.pathFunc != nil {
+		return o.pathFunc(r)
+	}
+	if o.routeName {
+		if name, ok := routeName(r); ok {
+			return name
+		}
+	}
+	if o.matchPath != nil && !hasRoute(r) {
+		if path, ok := o.matchPath(r); ok {
+			return path
+		}
+	}
+	return metricsPath(r, o.unmatchedPath)
+}
+
+// WithPathExtractor makes the instrument take the path label from fn
+// instead of the route's path template, e.g. to strip a version prefix.
+// Options rewriting the path, like WithRouteGroups, are applied to what fn
+// returns. fn must keep the number of distinct values low.
+func WithPathExtractor(fn func(*http.Request) string) Option {
+	return func(o *options) {
+		o.pathFunc = fn
+	}
+}
+
+// WithUnmatchedPath sets the path label of requests for which no route
+// matched, or whose route has no path template, to label instead of
+// "<unmatched>". All such requests share a single series.
+func WithUnmatchedPath(label string) Option {
+	return func(o *options) {
+		o.unmatchedPath = label
+	}
+}
+
+func (o *options) method(r *http.Request) string {
+	m := r.Method
+	if o.routeMethod {
+		if rm, ok := routeMethod(r); ok {
+			m = rm
+		}
+	}
+	if o.methods != nil {
+		return o.methods.get(sanitizeMethod(m))
+	}
+	return sanitizeMethod(m)
+}
+
+// WithMethodAllowlist limits the values of the method label to methods,
+// matched case insensitively, or to the standard HTTP methods if none are
+// given. All other methods are labeled "other". Without it, any method a
+// client makes up gets its own series, which internet facing servers
+// should not allow.
+func WithMethodAllowlist(methods ...string) Option {
+	if len(methods) == 0 {
+		methods = standardMethods
+	}
+	sanitized := make([]string, len(methods))
+	for i, m := range methods {
+		sanitized[i] = sanitizeMethod(m)
+	}
+	set := newStringSet(sanitized)
+	return func(o *options) {
+		o.methods = set
+	}
+}
+
+// otherLabelValue is used for label values outside of a configured set.
+const otherLabelValue = "other"
+
+// noneLabelValue is used by the options labeling requests by something that
+// may be missing, like a header, when it is.
+const noneLabelValue = "none"
+
+type stringSet map[string]struct{}
+
+func newStringSet(values []string) stringSet {
+	s := make(stringSet, len(values))
+	for _, v := range values {
+		s[v] = struct{}{}
+	}
+	return s
+}
+
+// get returns v if it is in s and otherLabelValue otherwise.
+func (s stringSet) get(v string) string {
+	if _, ok := s[v]; ok {
+		return v
+	}
+	return otherLabelValue
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// BenchmarkLabels compares building the labels of a request with the map
+// handed back to the pool, as the instruments do, to not releasing it,
+// which allocates a new map for every request.
+func BenchmarkLabels(b *testing.B) {
+	o := newOptions(nil)
+	r := httptest.NewRequest("GET", "/users/42", nil)
+	d := newDelegator(httptest.NewRecorder(), nil)
+	d.WriteHeader(200)
+
+	b.Run("released", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			releaseLabels(o.labels(r, d))
+		}
+	})
+	b.Run("dropped", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = o.labels(r, d)
+		}
+	})
+}
+
+// TestEntryLabelsInEveryInstrument checks that instruments not measuring a
+// duration take entry labels before the handler runs too.
+func TestEntryLabelsInEveryInstrument(t *testing.T) {
+	depth := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		depth = 100
+		ReportDBWait(r.Context(), time.Second)
+		ReportCacheOutcome(r.Context(), CacheHit)
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	opt := WithQueueDepthLabel(func() int { return depth })
+	for _, tc := range []struct {
+		name  string
+		build func() (prometheus.Collector, http.Handler)
+	}{
+		{"RequestSize", func() (prometheus.Collector, http.Handler) {
+			obs := newDurationVec("queue_depth")
+			return obs, InstrumentHandlerRequestSize(obs, next, opt)
+		}},
+		{"RequestHeaders", func() (prometheus.Collector, http.Handler) {
+			obs := newDurationVec("queue_depth")
+			return obs, InstrumentHandlerRequestHeaders(obs, newDurationVec("queue_depth"), next, opt)
+		}},
+		{"DBWait", func() (prometheus.Collector, http.Handler) {
+			obs := newDurationVec("queue_depth")
+			return obs, InstrumentHandlerDBWait(obs, next, opt)
+		}},
+		{"RetryAfter", func() (prometheus.Collector, http.Handler) {
+			obs := newDurationVec("queue_depth")
+			return obs, InstrumentHandlerRetryAfter(obs, next, opt)
+		}},
+		{"CacheOutcomes", func() (prometheus.Collector, http.Handler) {
+			counter := newCounterVec("queue_depth", "cache")
+			return counter, InstrumentHandlerCacheOutcomes(counter, next, opt)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, h := tc.build()
+			depth = 3
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+			count, _ := prommuxtest.CollectObservation(t, c, prometheus.Labels{
+				"code": "503", "queue_depth": "1-5",
+			})
+			if count != 1 {
+				t.Errorf("got %d observations with the depth on arrival, want 1", count)
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		new  func(obs prometheus.ObserverVec, next http.Handler, opts ...Option) http.Handler
+	}{
+		{"InstrumentHandlerDuration", func(obs prometheus.ObserverVec, next http.Handler, opts ...Option) http.Handler {
+			return InstrumentHandlerDuration(obs, next, opts...)
+		}},
+		{"InstrumentHandlerDurationFast", func(obs prometheus.ObserverVec, next http.Handler, opts ...Option) http.Handler {
+			return InstrumentHandlerDurationFast(obs, next, opts...)
+		}},
+		{"InstrumentHandlerRecovery", func(obs prometheus.ObserverVec, next http.Handler, opts ...Option) http.Handler {
+			return InstrumentHandlerRecovery(obs, next, opts...)
+		}},
+		{"InstrumentHandler", func(obs prometheus.ObserverVec, next http.Handler, opts ...Option) http.Handler {
+			return InstrumentHandler(HandlerMetrics{Duration: obs}, next, opts...)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec()
+			h := tc.new(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				d, _ := time.ParseDuration(r.URL.Query().Get("d"))
+				clock.Advance(d)
+			}), WithClock(clock.Now))
+			for _, d := range []string{"1.5s", "250ms", "0s"} {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/?d="+d, nil))
+			}
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"})
+			if count != 3 || sum != 1.75 {
+				t.Errorf("got %d observations summing to %v, want 3 summing to 1.75", count, sum)
+			}
+		})
+	}
+}
+
+func TestWithPathExtractor(t *testing.T) {
+	unversioned := func(r *http.Request) string {
+		path, _ := mux.CurrentRoute(r).GetPathTemplate()
+		return strings.TrimPrefix(path, "/v1")
+	}
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		path string
+	}{
+		{"template", nil, "/v1/users/{id}"},
+		{"extractor", []Option{WithPathExtractor(unversioned)}, "/users/{id}"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			router.Use(Middleware(obs, tc.opts...))
+			router.HandleFunc("/v1/users/{id}", func(http.ResponseWriter, *http.Request) {})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/users/1", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.path}); count != 1 {
+				t.Errorf("got %d observations with path %q, want 1", count, tc.path)
+			}
+		})
+	}
+}
+
+func TestWithMethodAllowlist(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		allow  []string
+		method string
+		want   string
+	}{
+		{"standard", nil, "PATCH", "patch"},
+		{"standard made up", nil, "PROPFIND", otherLabelValue},
+		{"standard lowercase made up", nil, "brew", otherLabelValue},
+		{"listed", []string{"get", "PROPFIND"}, "PROPFIND", "propfind"},
+		{"listed case insensitive", []string{"propfind"}, "PROPFIND", "propfind"},
+		{"standard not listed", []string{"GET"}, "POST", otherLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+				WithMethodAllowlist(tc.allow...))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"method": tc.want}); count != 1 {
+				t.Errorf("got %d observations with method %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package prom_mux
+
+import "strings"
+
+// WithPathPrefixTrim strips prefix, e.g. "/api/v1", from the start of the
+// "path" label, so that "/api/v1/users/{id}" is labeled "/users/{id}". The
+// prefix only matches whole path segments: with the prefix above,
+// "/api/v10/users" is left unchanged, like any other path not starting with
+// it. A path equal to the prefix is labeled "/".
+func WithPathPrefixTrim(prefix string) Option {
+	return func(o *options) {
+		o.addPathRewrite(func(path string) string {
+			if !strings.HasPrefix(path, prefix) {
+				return path
+			}
+			rest := path[len(prefix):]
+			if rest == "" {
+				return "/"
+			}
+			if !strings.HasSuffix(prefix, "/") && rest[0] != '/' {
+				return path
+			}
+			if rest[0] != '/' {
+				rest = "/" + rest
+			}
+			return rest
+		})
+	}
+}
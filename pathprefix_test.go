@@ -0,0 +1,40 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithPathPrefixTrim(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		prefix   string
+		template string
+		target   string
+		want     string
+	}{
+		{"segment", "/api", "/api/users/{id}", "/api/users/1", "/users/{id}"},
+		{"longer segment", "/api", "/apiv2/users/{id}", "/apiv2/users/1", "/apiv2/users/{id}"},
+		{"exact", "/api", "/api", "/api", "/"},
+		{"no match", "/api", "/users/{id}", "/users/1", "/users/{id}"},
+		{"trailing slash", "/api/", "/api/users/{id}", "/api/users/1", "/users/{id}"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			router.Use(Middleware(obs, WithPathPrefixTrim(tc.prefix)))
+			router.HandleFunc(tc.template, func(http.ResponseWriter, *http.Request) {})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.target, nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.want}); count != 1 {
+				t.Errorf("got %d observations with path %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
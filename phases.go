@@ -0,0 +1,82 @@
+package prom_mux
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Values of the "phase" label used by InstrumentHandlerPhases.
+const (
+	phaseRead  = "read"
+	phaseWrite = "write"
+)
+
+// firstReadBody records the moment the first byte of the request body was
+// read by the wrapped handler.
+type firstReadBody struct {
+	io.ReadCloser
+
+	now       func() time.Time
+	firstRead time.Time
+}
+
+func (b *firstReadBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.firstRead.IsZero() {
+		b.firstRead = b.now()
+	}
+	return n, err
+}
+
+// InstrumentHandlerPhases splits the time spent in next into a read and a
+// write phase and observes both with obs, which must be partitioned by
+// "code", "method", "path" and "phase".
+//
+// The "read" phase is the time from entering the handler until the first
+// byte of the request body has been read. The "write" phase is the time from
+// the response header being written (explicitly or by the first Write) until
+// the handler returns. Both are approximations: time spent between the two
+// phases is attributed to neither, and a phase that never happened (the body
+// was not read or nothing was written) is not observed at all.
+func InstrumentHandlerPhases(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerPhases", "observer", obs)
+	mustNotBeNil("InstrumentHandlerPhases", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs, "phase")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		var body *firstReadBody
+		if r.Body != nil {
+			body = &firstReadBody{ReadCloser: r.Body, now: o.now}
+			r.Body = body
+		}
+		var firstWrite time.Time
+		d := newDelegator(w, func(int) {
+			firstWrite = o.now()
+		})
+		next.ServeHTTP(d, r)
+		end := o.now()
+
+		labels := o.labels(r, d)
+		if body != nil && !body.firstRead.IsZero() {
+			labels["phase"] = phaseRead
+			read := body.firstRead.Sub(now)
+			o.observe(obs.With(labels), read.Seconds(), r, d, end.Sub(now))
+		}
+		if !firstWrite.IsZero() {
+			labels["phase"] = phaseWrite
+			write := end.Sub(firstWrite)
+			o.observe(obs.With(labels), write.Seconds(), r, d, end.Sub(now))
+		}
+		releaseLabels(labels)
+	}
+}
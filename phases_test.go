@@ -0,0 +1,73 @@
+package prom_mux
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerPhases(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		body      string
+		write     bool
+		wantRead  float64 // 0: not observed
+		wantWrite float64 // 0: not observed
+	}{
+		{"read and write", "payload", true, 0.1, 0.3},
+		{"no body", "", true, 0, 0.3},
+		{"nothing written", "payload", false, 0.1, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec("phase")
+			h := InstrumentHandlerPhases(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(100 * time.Millisecond)
+				ioutil.ReadAll(r.Body)
+				clock.Advance(50 * time.Millisecond)
+				if tc.write {
+					w.WriteHeader(http.StatusOK)
+					clock.Advance(300 * time.Millisecond)
+				}
+			}), WithClock(clock.Now))
+			var r *http.Request
+			if tc.body != "" {
+				r = httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			} else {
+				r = httptest.NewRequest("GET", "/", nil)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			for _, phase := range []struct {
+				name string
+				want float64
+			}{
+				{phaseRead, tc.wantRead},
+				{phaseWrite, tc.wantWrite},
+			} {
+				count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"phase": phase.name})
+				switch {
+				case phase.want == 0 && count != 0:
+					t.Errorf("%s phase observed %d times, want none", phase.name, count)
+				case phase.want != 0 && (count != 1 || !approx(sum, phase.want)):
+					t.Errorf("%s phase: got %d observations summing to %v, want 1 of %v",
+						phase.name, count, sum, phase.want)
+				}
+			}
+		})
+	}
+}
+
+// approx reports whether the durations in seconds a and b are equal but for
+// rounding errors.
+func approx(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
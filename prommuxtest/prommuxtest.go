@@ -0,0 +1,229 @@
+// Package prommuxtest provides utilities for testing instrumented handlers
+// deterministically: a fake clock to pass to prom_mux.WithClock, a Barrier
+// to hold requests inside a handler while assertions are made, and
+// CollectObservation to read back what was observed.
+//
+// For example, to check an in-flight gauge while three requests are being
+// served:
+//
+//	b := prommuxtest.NewBarrier()
+//	h := prom_mux.InstrumentHandlerInFlight(gauge, b.Handler(nil))
+//	var results []<-chan *httptest.ResponseRecorder
+//	for i := 0; i < 3; i++ {
+//		results = append(results, prommuxtest.Serve(h, httptest.NewRequest("GET", "/", nil)))
+//	}
+//	b.WaitHeld(3)
+//	if _, v := prommuxtest.CollectObservation(t, gauge, nil); v != 3 {
+//		t.Errorf("in-flight gauge is %v, want 3", v)
+//	}
+//	b.ReleaseAll()
+//	for _, res := range results {
+//		<-res
+//	}
+package prommuxtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Clock is a fake clock. Its Now method can be passed to
+// prom_mux.WithClock. It is safe for concurrent use.
+type Clock struct {
+	mtx sync.Mutex
+	now time.Time
+}
+
+// NewClock returns a Clock set to t.
+func NewClock(t time.Time) *Clock {
+	return &Clock{now: t}
+}
+
+// Now returns the current time of the clock.
+func (c *Clock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Barrier holds the goroutines calling Wait until they are released. Tests
+// use it to stop requests at a known point inside a handler. The zero value
+// is not usable, create barriers with NewBarrier.
+type Barrier struct {
+	mtx      sync.Mutex
+	cond     *sync.Cond
+	held     int
+	released int
+	open     bool
+}
+
+// NewBarrier returns a closed Barrier.
+func NewBarrier() *Barrier {
+	b := &Barrier{}
+	b.cond = sync.NewCond(&b.mtx)
+	return b
+}
+
+// Wait blocks until the caller is released by ReleaseOne or ReleaseAll.
+func (b *Barrier) Wait() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.held++
+	b.cond.Broadcast()
+	for !b.open && b.released == 0 {
+		b.cond.Wait()
+	}
+	if !b.open {
+		b.released--
+	}
+	b.held--
+	b.cond.Broadcast()
+}
+
+// Held returns the number of goroutines currently blocked in Wait.
+func (b *Barrier) Held() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.held
+}
+
+// WaitHeld blocks until at least n goroutines are blocked in Wait.
+func (b *Barrier) WaitHeld(n int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for b.held < n {
+		b.cond.Wait()
+	}
+}
+
+// ReleaseOne lets one goroutine blocked in Wait, or the next one to call
+// it, continue.
+func (b *Barrier) ReleaseOne() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.released++
+	b.cond.Broadcast()
+}
+
+// ReleaseAll opens the barrier: all goroutines blocked in Wait continue and
+// later calls to Wait return immediately.
+func (b *Barrier) ReleaseAll() {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.open = true
+	b.cond.Broadcast()
+}
+
+// Handler returns a handler that waits at the barrier and then calls next.
+// If next is nil, it responds with an empty 200 instead.
+func (b *Barrier) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b.Wait()
+		if next != nil {
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// Serve serves r with h in a new goroutine. The returned channel receives
+// the recorded response once h returns.
+func Serve(h http.Handler, r *http.Request) <-chan *httptest.ResponseRecorder {
+	ch := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		ch <- rec
+	}()
+	return ch
+}
+
+// CollectObservation returns the number of observations and their sum
+// recorded by c, typically a HistogramVec, SummaryVec or CounterVec, for the
+// series with labels. Labels not given, like constant ones, are not
+// compared. For counters and gauges, count is the value truncated to an
+// integer and sum the value itself. If there is no such series, both are
+// 0. It fails the test if labels match more than one series.
+//
+// For example, to check that a request was observed with the right labels:
+//
+//	count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{
+//		"code": "404", "method": "get", "path": "/users/{id}",
+//	})
+//	if count != 1 {
+//		t.Errorf("got %d observations, want 1", count)
+//	}
+func CollectObservation(
+	t testing.TB, c prometheus.Collector, labels prometheus.Labels,
+) (count uint64, sum float64) {
+	t.Helper()
+	// Collect everything before failing the test, if need be, so the
+	// goroutine running Collect doesn't block forever.
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	found := 0
+	for _, m := range metrics {
+		var pm dto.Metric
+		if err := m.Write(&pm); err != nil {
+			t.Fatalf("writing metric: %v", err)
+		}
+		if !hasLabels(&pm, labels) {
+			continue
+		}
+		found++
+		switch {
+		case pm.GetHistogram() != nil:
+			count, sum = pm.GetHistogram().GetSampleCount(), pm.GetHistogram().GetSampleSum()
+		case pm.GetSummary() != nil:
+			count, sum = pm.GetSummary().GetSampleCount(), pm.GetSummary().GetSampleSum()
+		case pm.GetCounter() != nil:
+			sum = pm.GetCounter().GetValue()
+			count = uint64(sum)
+		case pm.GetGauge() != nil:
+			sum = pm.GetGauge().GetValue()
+			count = uint64(sum)
+		case pm.GetUntyped() != nil:
+			sum = pm.GetUntyped().GetValue()
+			count = uint64(sum)
+		}
+	}
+	if found > 1 {
+		t.Fatalf("labels %v match %d series, want at most 1", labels, found)
+	}
+	return count, sum
+}
+
+// hasLabels reports whether m has all of labels.
+func hasLabels(m *dto.Metric, labels prometheus.Labels) bool {
+	matched := 0
+	for _, lp := range m.GetLabel() {
+		v, ok := labels[lp.GetName()]
+		if !ok {
+			continue
+		}
+		if v != lp.GetValue() {
+			return false
+		}
+		matched++
+	}
+	return matched == len(labels)
+}
@@ -0,0 +1,61 @@
+package prommuxtest_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	prom_mux "github.com/olomix/prom-mux"
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// TestBarrierInFlight runs the example of the package documentation.
+func TestBarrierInFlight(t *testing.T) {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "test_requests_in_flight",
+		Help: "Test requests in flight.",
+	})
+	b := prommuxtest.NewBarrier()
+	h := prom_mux.InstrumentHandlerInFlight(gauge, b.Handler(nil))
+	// Any of the requests may be the one ReleaseOne lets through, so their
+	// results are merged.
+	done := make(chan *httptest.ResponseRecorder, 3)
+	for i := 0; i < 3; i++ {
+		res := prommuxtest.Serve(h, httptest.NewRequest("GET", "/", nil))
+		go func() { done <- <-res }()
+	}
+	b.WaitHeld(3)
+	if _, v := prommuxtest.CollectObservation(t, gauge, nil); v != 3 {
+		t.Errorf("in-flight gauge is %v, want 3", v)
+	}
+
+	b.ReleaseOne()
+	<-done
+	if held := b.Held(); held != 2 {
+		t.Errorf("%d requests held after releasing one, want 2", held)
+	}
+	if _, v := prommuxtest.CollectObservation(t, gauge, nil); v != 2 {
+		t.Errorf("in-flight gauge is %v, want 2", v)
+	}
+
+	b.ReleaseAll()
+	for i := 0; i < 2; i++ {
+		if rec := <-done; rec.Code != 200 {
+			t.Errorf("got status %d, want 200", rec.Code)
+		}
+	}
+	if _, v := prommuxtest.CollectObservation(t, gauge, nil); v != 0 {
+		t.Errorf("in-flight gauge is %v, want 0", v)
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := prommuxtest.NewClock(start)
+	c.Advance(1500 * time.Millisecond)
+	if got, want := c.Now(), start.Add(1500*time.Millisecond); !got.Equal(want) {
+		t.Errorf("Now() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package prom_mux
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// WithPushCounter makes InstrumentHandlerDuration and InstrumentHandler add
+// the number of HTTP/2 server pushes a request initiated successfully to
+// counter, partitioned like the instrument's observer. Requests without a
+// push are not counted.
+//
+// The pushed responses themselves are served by separate runs of the
+// handler and show up in the metrics like regular GET requests, so their
+// sizes and durations are neither lost nor counted twice.
+func WithPushCounter(counter *prometheus.CounterVec) Option {
+	return func(o *options) {
+		o.pushCounter = counter
+	}
+}
+
+func (o *options) countPushes(d Delegator, labels prometheus.Labels) {
+	if o.pushCounter == nil {
+		return
+	}
+	p, ok := d.(interface{ pushes() int })
+	if !ok || p.pushes() == 0 {
+		return
+	}
+	o.pushCounter.With(labels).Add(float64(p.pushes()))
+}
+
+func (r *responseWriterDelegator) pushes() int {
+	return r.pushed
+}
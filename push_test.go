@@ -0,0 +1,65 @@
+package prom_mux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// pushRecorder is a ResponseRecorder supporting server pushes, except for
+// the target "/fail".
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (pushRecorder) Push(target string, _ *http.PushOptions) error {
+	if target == "/fail" {
+		return errors.New("push failed")
+	}
+	return nil
+}
+
+func TestWithPushCounter(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		targets []string
+		want    uint64
+	}{
+		{"none", nil, 0},
+		{"one", []string{"/style.css"}, 1},
+		{"several", []string{"/style.css", "/app.js", "/logo.png"}, 3},
+		{"failed", []string{"/style.css", "/fail"}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs, size, pushes := newDurationVec(), newDurationVec(), newCounterVec()
+			handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				for _, target := range tc.targets {
+					w.(http.Pusher).Push(target, nil)
+				}
+				w.Write([]byte("page"))
+			})
+			h := InstrumentHandlerDuration(obs,
+				InstrumentHandlerResponseSize(size, handler),
+				WithPushCounter(pushes))
+			h.ServeHTTP(pushRecorder{httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+
+			labels := prometheus.Labels{"code": "200"}
+			if count, _ := prommuxtest.CollectObservation(t, pushes, labels); count != tc.want {
+				t.Errorf("got %d pushes, want %d", count, tc.want)
+			}
+			if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+				t.Errorf("got %d observations of the pushing request, want 1", count)
+			}
+			// Pushed responses don't count towards the size of the
+			// response that pushed them.
+			if count, sum := prommuxtest.CollectObservation(t, size, labels); count != 1 || sum != 4 {
+				t.Errorf("got %d size observations summing to %v, want 1 of 4", count, sum)
+			}
+		})
+	}
+}
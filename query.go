@@ -0,0 +1,21 @@
+package prom_mux
+
+import "net/http"
+
+// WithQueryParamLabel adds a label called name holding the value of the
+// query parameter of the same name, e.g. "source" for "?source=ios". Only
+// the allowed values are used as label values; requests without the
+// parameter are labeled "none" and all others "other". name must be a
+// valid label name.
+func WithQueryParamLabel(name string, allowed ...string) Option {
+	set := newStringSet(allowed)
+	return func(o *options) {
+		o.addLabel(name, func(r *http.Request, _ Delegator) string {
+			v := r.URL.Query().Get(name)
+			if v == "" {
+				return noneLabelValue
+			}
+			return set.get(v)
+		})
+	}
+}
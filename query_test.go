@@ -0,0 +1,47 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithQueryParamLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{"allowed", "/?source=ios", "ios"},
+		{"other allowed", "/?page=2&source=android", "android"},
+		{"first of several", "/?source=ios&source=web", "ios"},
+		{"disallowed", "/?source=../../etc/passwd", otherLabelValue},
+		{"case sensitive", "/?source=IOS", otherLabelValue},
+		{"missing", "/?page=2", noneLabelValue},
+		{"empty", "/?source=", noneLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("source")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+				WithQueryParamLabel("source", "ios", "android"))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.target, nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"source": tc.want}); count != 1 {
+				t.Errorf("got %d observations for %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithQueryParamLabelMissingFromVector(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("vector without the query parameter label did not panic")
+		}
+	}()
+	InstrumentHandlerDuration(newDurationVec(), http.NotFoundHandler(), WithQueryParamLabel("source", "ios"))
+}
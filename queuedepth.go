@@ -0,0 +1,29 @@
+package prom_mux
+
+import "net/http"
+
+// WithQueueDepthLabel adds a "queue_depth" label holding the value returned
+// by depth, typically the backlog of the worker pool the handler dispatches
+// to, when the request arrived. The depth is bucketed into "0", "1-5",
+// "6-20" and "21+" to keep the label bounded, which is enough to correlate
+// latency with saturation.
+func WithQueueDepthLabel(depth func() int) Option {
+	return func(o *options) {
+		o.addEntryLabel("queue_depth", func(*http.Request) string {
+			return queueDepthBucket(depth())
+		})
+	}
+}
+
+func queueDepthBucket(depth int) string {
+	switch {
+	case depth <= 0:
+		return "0"
+	case depth <= 5:
+		return "1-5"
+	case depth <= 20:
+		return "6-20"
+	default:
+		return "21+"
+	}
+}
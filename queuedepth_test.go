@@ -0,0 +1,38 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithQueueDepthLabel(t *testing.T) {
+	depth := 0
+	obs := newDurationVec("queue_depth")
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		// The depth on arrival counts, not the one once served.
+		depth = 1000
+	}), WithQueueDepthLabel(func() int { return depth }))
+
+	for _, d := range []int{-1, 0, 1, 5, 6, 20, 21} {
+		depth = d
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	for _, tc := range []struct {
+		bucket string
+		want   uint64
+	}{
+		{"0", 2},
+		{"1-5", 2},
+		{"6-20", 2},
+		{"21+", 1},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"queue_depth": tc.bucket}); count != tc.want {
+			t.Errorf("got %d observations with queue depth %s, want %d", count, tc.bucket, tc.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package prom_mux
+
+import (
+	"math/rand"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithRawPathSampling makes InstrumentHandlerDuration additionally count a
+// random sample of requests, chosen with probability rate, in counter with
+// the raw URL path (without the query) as path label. counter has the
+// same labels as the observer passed to InstrumentHandlerDuration, which
+// keeps using the path template (with WithRouteHashLabel, "path" takes the
+// place of "route_hash").
+//
+// This is a debugging aid for finding which concrete URLs are behind a
+// route. The raw path is unbounded, so keep rate low and the counter
+// separate from the metrics used for dashboards and alerts.
+func WithRawPathSampling(counter *prometheus.CounterVec, rate float64) Option {
+	return func(o *options) {
+		o.rawPathCounter = counter
+		o.rawPathRate = rate
+	}
+}
+
+func (o *options) sampleRawPath(r *http.Request, labels prometheus.Labels) {
+	if o.rawPathCounter == nil || rand.Float64() >= o.rawPathRate {
+		return
+	}
+	raw := make(prometheus.Labels, len(labels))
+	for name, value := range labels {
+		raw[name] = value
+	}
+	delete(raw, o.pathLabelName())
+	raw[o.pathLabel] = r.URL.Path
+	o.rawPathCounter.With(raw).Inc()
+}
@@ -0,0 +1,47 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRawPathSampling(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		pathLabel string
+		opts      []Option
+	}{
+		{"path", "path", nil},
+		{"route hash", routeHashLabel, []Option{WithRouteHashLabel()}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name: "test_request_duration_seconds",
+				Help: "Test request durations.",
+			}, []string{"code", "method", tc.pathLabel, "version"})
+			raw := newCounterVec("version")
+			opts := append([]Option{WithRawPathSampling(raw, 1), WithBuildInfoLabel("1.2.3")}, tc.opts...)
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), opts...)
+			for _, target := range []string{"/users/1", "/users/2", "/users/1?q=x"} {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+			}
+			for _, c := range []struct {
+				path string
+				want uint64
+			}{
+				{"/users/1", 2},
+				{"/users/2", 1},
+			} {
+				labels := prometheus.Labels{"path": c.path, "version": "1.2.3"}
+				if count, _ := prommuxtest.CollectObservation(t, raw, labels); count != c.want {
+					t.Errorf("got %d samples with labels %v, want %d", count, labels, c.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithPanicResponse makes InstrumentHandlerRecovery answer requests whose
+// handler panicked with a 500 Internal Server Error, if nothing was written
+// yet, instead of panicking again.
+func WithPanicResponse() Option {
+	return func(o *options) {
+		o.panicResponse = true
+	}
+}
+
+// InstrumentHandlerRecovery is like InstrumentHandlerDuration, but also
+// observes requests whose handler panicked, as answered with a 500 whatever
+// the handler had written. The panic is then passed on, unless
+// WithPanicResponse is used. Panics with http.ErrAbortHandler, which
+// handlers use to abort a response on purpose, are observed as well but
+// always passed on.
+func InstrumentHandlerRecovery(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerRecovery", "observer", obs)
+	mustNotBeNil("InstrumentHandlerRecovery", "handler", next)
+	o := newOptions(opts)
+	obs = o.prepareObservers(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d, rwd := acquireDelegator(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			wroteHeader := rwd.wroteHeader
+			rwd.status = http.StatusInternalServerError
+			o.recordDuration(obs, r, d, now)
+			if !o.panicResponse || p == http.ErrAbortHandler {
+				panic(p)
+			}
+			if !wroteHeader {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(d, r)
+
+		o.recordDuration(obs, r, d, now)
+		releaseDelegator(rwd)
+	}
+}
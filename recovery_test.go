@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerRecovery(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		opts     []Option
+		handler  func(w http.ResponseWriter)
+		panics   bool
+		response int
+		code     string
+	}{
+		{"no panic", nil, func(w http.ResponseWriter) { w.WriteHeader(http.StatusCreated) }, false, 201, "201"},
+		{"panic", nil, func(http.ResponseWriter) { panic("boom") }, true, 0, "500"},
+		{"panic response", []Option{WithPanicResponse()}, func(http.ResponseWriter) { panic("boom") }, false, 500, "500"},
+		{"panic after writing", []Option{WithPanicResponse()}, func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusOK)
+			panic("boom")
+		}, false, 200, "500"},
+		{"abort", []Option{WithPanicResponse()}, func(http.ResponseWriter) { panic(http.ErrAbortHandler) }, true, 0, "500"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			router := mux.NewRouter()
+			router.Use(func(next http.Handler) http.Handler {
+				return InstrumentHandlerRecovery(obs, next, tc.opts...)
+			})
+			router.HandleFunc("/users/{id}", func(w http.ResponseWriter, _ *http.Request) {
+				tc.handler(w)
+			})
+			w := httptest.NewRecorder()
+			func() {
+				defer func() {
+					if p := recover(); (p != nil) != tc.panics {
+						t.Errorf("got panic %v, want panic: %v", p, tc.panics)
+					}
+				}()
+				router.ServeHTTP(w, httptest.NewRequest("POST", "/users/1", nil))
+			}()
+			if !tc.panics && w.Code != tc.response {
+				t.Errorf("got response %d, want %d", w.Code, tc.response)
+			}
+
+			labels := prometheus.Labels{"code": tc.code, "method": "post", "path": "/users/{id}"}
+			if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package prom_mux
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+
+	read int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// InstrumentHandlerRequestSize observes with obs the size of the request
+// body, partitioned like for InstrumentHandlerDuration. If the request
+// declares its size in the Content-Length header, that is what gets
+// observed, whether or not next reads the body. Otherwise, e.g. for chunked
+// requests, the bytes next actually read are counted, so a body the handler
+// ignores is observed as 0.
+//
+// The observation is always made after next returned, since the status code
+// is one of the labels.
+func InstrumentHandlerRequestSize(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerRequestSize", "observer", obs)
+	mustNotBeNil("InstrumentHandlerRequestSize", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		body := countRequestBody(r)
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), float64(requestSize(r, body)), r, d, o.now().Sub(now))
+		releaseLabels(labels)
+		releaseDelegator(rwd)
+	}
+}
+
+// countRequestBody wraps the body of r into a countingBody if its size is
+// unknown, and returns the countingBody, if any.
+func countRequestBody(r *http.Request) *countingBody {
+	if r.ContentLength >= 0 || r.Body == nil {
+		return nil
+	}
+	body := &countingBody{ReadCloser: r.Body}
+	r.Body = body
+	return body
+}
+
+// requestSize returns the size of the body of r as described for
+// InstrumentHandlerRequestSize. body is what countRequestBody returned.
+func requestSize(r *http.Request, body *countingBody) int64 {
+	if body != nil {
+		return body.read
+	}
+	if r.ContentLength < 0 {
+		return 0
+	}
+	return r.ContentLength
+}
+
+// WithContentTypeLabel adds a "content_type" label holding the media type
+// of the request, as sent in the Content-Type header without parameters
+// and lowercased, e.g. "application/json" or "multipart/form-data". Only
+// the allowed media types are used as label values; requests without the
+// header are labeled "none" and all others "other". Combined with
+// InstrumentHandlerRequestSize, it breaks upload sizes down by type.
+func WithContentTypeLabel(allowed ...string) Option {
+	types := make([]string, len(allowed))
+	for i, t := range allowed {
+		types[i] = strings.ToLower(t)
+	}
+	set := newStringSet(types)
+	return func(o *options) {
+		o.addLabel("content_type", func(r *http.Request, _ Delegator) string {
+			ct := r.Header.Get("Content-Type")
+			if ct == "" {
+				return noneLabelValue
+			}
+			return set.get(mediaType(ct))
+		})
+	}
+}
+
+// mediaType returns the lowercased media type of a Content-Type header
+// value, or "" if it can't be parsed.
+func mediaType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return t
+}
@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerRequestSizeContentType(t *testing.T) {
+	var upload bytes.Buffer
+	mw := multipart.NewWriter(&upload)
+	part, _ := mw.CreateFormFile("file", "a.bin")
+	part.Write(make([]byte, 1000))
+	mw.Close()
+
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		body        string
+		chunked     bool
+		want        string
+	}{
+		{"json", "application/json", `{"a":1}`, false, "application/json"},
+		{"json with charset", "Application/JSON; charset=utf-8", `{"a":1}`, false, "application/json"},
+		{"multipart", mw.FormDataContentType(), upload.String(), false, "multipart/form-data"},
+		{"chunked multipart", mw.FormDataContentType(), upload.String(), true, "multipart/form-data"},
+		{"not allowed", "text/plain", "hello", false, otherLabelValue},
+		{"malformed", "/", "hello", false, otherLabelValue},
+		{"no content type", "", "hello", false, noneLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("content_type")
+			h := InstrumentHandlerRequestSize(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(ioutil.Discard, r.Body)
+			}), WithContentTypeLabel("application/json", "multipart/form-data"))
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tc.body))
+			if tc.chunked {
+				r.ContentLength = -1
+			}
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"content_type": tc.want})
+			if count != 1 || sum != float64(len(tc.body)) {
+				t.Errorf("got %d observations summing to %v for %q, want 1 of %d", count, sum, tc.want, len(tc.body))
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerResponseSize observes with obs the number of response
+// body bytes written by next, partitioned like for
+// InstrumentHandlerDuration. Bytes copied to the response with io.Copy are
+// included whether or not the underlying ResponseWriter implements
+// io.ReaderFrom (see Delegator.Written); headers are not.
+func InstrumentHandlerResponseSize(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerResponseSize", "observer", obs)
+	mustNotBeNil("InstrumentHandlerResponseSize", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), float64(d.Written()), r, d, o.now().Sub(now))
+		releaseLabels(labels)
+		releaseDelegator(rwd)
+	}
+}
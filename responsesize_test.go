@@ -0,0 +1,86 @@
+package prom_mux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerResponseSize(t *testing.T) {
+	obs := newDurationVec()
+	h := InstrumentHandlerResponseSize(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body := r.URL.Query().Get("body"); body != "" {
+			w.Write([]byte(body))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// The delegator of the first request is likely reused by the second,
+	// which must not see the bytes written by the first.
+	for _, target := range []string{"/?body=hello", "/"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	for _, tc := range []struct {
+		code string
+		sum  float64
+	}{
+		{"200", 5},
+		{"204", 0},
+	} {
+		count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.code})
+		if count != 1 || sum != tc.sum {
+			t.Errorf("code %s: got %d observations summing to %v, want 1 of %v", tc.code, count, sum, tc.sum)
+		}
+	}
+}
+
+func TestInstrumentHandlerResponseSizeCopy(t *testing.T) {
+	const body = "copied with io.Copy"
+	for _, tc := range []struct {
+		name     string
+		w        http.ResponseWriter
+		src      io.Reader
+		readFrom int
+	}{
+		{"Write", httptest.NewRecorder(), onlyReader{strings.NewReader(body)}, 0},
+		{"ReadFrom", &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}, onlyReader{strings.NewReader(body)}, 1},
+		{"WriterTo", &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}, strings.NewReader(body), 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerResponseSize(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, tc.src)
+			}))
+			h.ServeHTTP(tc.w, httptest.NewRequest("GET", "/", nil))
+			if w, ok := tc.w.(*readerFromRecorder); ok && w.readFrom != tc.readFrom {
+				t.Fatalf("ReadFrom called %d times, want %d", w.readFrom, tc.readFrom)
+			}
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"})
+			if count != 1 || sum != float64(len(body)) {
+				t.Errorf("got %d observations summing to %v, want 1 of %d", count, sum, len(body))
+			}
+		})
+	}
+}
+
+func BenchmarkInstrumentHandlerResponseSize(b *testing.B) {
+	h := InstrumentHandlerResponseSize(newDurationVec(), http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		},
+	))
+	r := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.ServeHTTP(w, r)
+	}
+}
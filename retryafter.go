@@ -0,0 +1,64 @@
+package prom_mux
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerRetryAfter observes with obs the backoff, in seconds,
+// suggested by the Retry-After header of the responses of next, typically
+// 429 and 503 responses of a service shedding load. obs is partitioned
+// like for InstrumentHandlerDuration. Responses without a Retry-After
+// header, or with one that can't be parsed, are not observed.
+//
+// Both forms of the header are supported: a number of seconds, and an HTTP
+// date, which is converted to the time left from when the handler
+// returned.
+func InstrumentHandlerRetryAfter(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerRetryAfter", "observer", obs)
+	mustNotBeNil("InstrumentHandlerRetryAfter", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		d := newDelegator(w, nil)
+		next.ServeHTTP(d, r)
+
+		end := o.now()
+		backoff, ok := parseRetryAfter(d.Header().Get("Retry-After"), end)
+		if !ok {
+			return
+		}
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), backoff.Seconds(), r, d, end.Sub(now))
+		releaseLabels(labels)
+	}
+}
+
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseUint(v, 10, 32); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if backoff := t.Sub(now); backoff > 0 {
+		return backoff, true
+	}
+	return 0, true
+}
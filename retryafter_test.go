@@ -0,0 +1,49 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name       string
+		retryAfter string
+		want       float64 // -1: not observed
+	}{
+		{"seconds", "120", 120},
+		{"padded seconds", " 5 ", 5},
+		{"date", now.Add(90 * time.Second).Format(http.TimeFormat), 90},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"missing", "", -1},
+		{"invalid", "soon", -1},
+		{"negative", "-5", -1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(now)
+			obs := newDurationVec()
+			h := InstrumentHandlerRetryAfter(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(http.StatusTooManyRequests)
+			}), WithClock(clock.Now))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "429"})
+			switch {
+			case tc.want < 0 && count != 0:
+				t.Errorf("got %d observations, want none", count)
+			case tc.want >= 0 && (count != 1 || sum != tc.want):
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, tc.want)
+			}
+		})
+	}
+}
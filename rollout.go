@@ -0,0 +1,33 @@
+package prom_mux
+
+import (
+	"hash/fnv"
+	"net/http"
+)
+
+// WithRouteRollout instruments only about percent (0 to 100) of the routes,
+// chosen by hashing their path template. Requests to the other routes are
+// passed on without any instrumentation overhead. The selection only
+// depends on the template, so it is stable across requests, restarts and
+// instances, and raising percent only ever adds routes.
+//
+// This is meant for rolling instrumentation out gradually across a large
+// router while measuring its overhead; it is not a way of sampling
+// requests. The path is determined before the handler runs, so the
+// instrument has to be used as router middleware or on individual routes
+// for the selection to be per route.
+func WithRouteRollout(percent float64) Option {
+	return func(o *options) {
+		o.skips = append(o.skips, func(r *http.Request) bool {
+			return !routeSelected(o.path(r), percent)
+		})
+	}
+}
+
+// routeSelected reports whether path is among the percent of routes
+// selected by WithRouteRollout.
+func routeSelected(path string, percent float64) bool {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return float64(h.Sum32()%10000) < percent*100
+}
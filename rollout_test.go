@@ -0,0 +1,80 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRouteRollout(t *testing.T) {
+	const routes = 200
+	// selected returns the routes observed with percent, each requested
+	// twice.
+	selected := func(t *testing.T, percent float64) map[string]bool {
+		cnt := newCounterVec()
+		router := mux.NewRouter()
+		router.Use(func(next http.Handler) http.Handler {
+			return InstrumentHandlerCounter(cnt, next, WithRouteRollout(percent))
+		})
+		for i := 0; i < routes; i++ {
+			router.HandleFunc(fmt.Sprintf("/route%d/{id}", i), func(http.ResponseWriter, *http.Request) {})
+		}
+		for n := 0; n < 2; n++ {
+			for i := 0; i < routes; i++ {
+				router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprintf("/route%d/1", i), nil))
+			}
+		}
+		got := map[string]bool{}
+		for i := 0; i < routes; i++ {
+			path := fmt.Sprintf("/route%d/{id}", i)
+			switch count, _ := prommuxtest.CollectObservation(t, cnt, prometheus.Labels{"path": path}); count {
+			case 0:
+			case 2:
+				got[path] = true
+			default:
+				t.Errorf("got %d observations for %s, want 0 or 2", count, path)
+			}
+		}
+		return got
+	}
+
+	var prev map[string]bool
+	for _, tc := range []struct {
+		percent  float64
+		min, max int
+	}{
+		{0, 0, 0},
+		{10, 10, 30},
+		{50, 80, 120},
+		{100, routes, routes},
+	} {
+		t.Run(fmt.Sprint(tc.percent), func(t *testing.T) {
+			got := selected(t, tc.percent)
+			if len(got) < tc.min || len(got) > tc.max {
+				t.Errorf("%v%% selected %d of %d routes, want %d to %d", tc.percent, len(got), routes, tc.min, tc.max)
+			}
+			// The selection is stable and only grows with percent.
+			again := selected(t, tc.percent)
+			for path := range prev {
+				if !got[path] {
+					t.Errorf("%s is not selected with %v%% anymore", path, tc.percent)
+				}
+			}
+			if len(again) != len(got) {
+				t.Errorf("selected %d routes, then %d", len(got), len(again))
+			}
+			for path := range got {
+				if !again[path] {
+					t.Errorf("%s is not selected again", path)
+				}
+			}
+			prev = got
+		})
+	}
+}
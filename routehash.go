@@ -0,0 +1,45 @@
+package prom_mux
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+)
+
+// routeHashLabel is the name of the label used by WithRouteHashLabel.
+const routeHashLabel = "route_hash"
+
+// WithRouteHashLabel replaces the "path" label with a "route_hash" label
+// holding 8 hex digits of the 32-bit FNV-1a hash of the path, e.g. of the
+// route template "/users/{id}". The hash only depends on the path, so it is
+// the same across restarts and processes, and lets metrics be joined with
+// systems that can't afford storing long paths, at the price of
+// readability. What is hashed is the path as it would otherwise have been
+// labeled, i.e. after options like WithRouteGroups rewrote it.
+func WithRouteHashLabel() Option {
+	return func(o *options) {
+		o.routeHash = true
+	}
+}
+
+// pathLabelName returns the name of the label identifying the route.
+func (o *options) pathLabelName() string {
+	if o.routeHash {
+		return routeHashLabel
+	}
+	return o.pathLabel
+}
+
+// pathLabelValue returns the value of the label named by pathLabelName.
+func (o *options) pathLabelValue(r *http.Request) string {
+	if o.routeHash {
+		return routeHash(o.path(r))
+	}
+	return o.path(r)
+}
+
+func routeHash(path string) string {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
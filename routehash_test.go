@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRouteHashLabel(t *testing.T) {
+	obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_request_duration_seconds",
+		Help: "Test request durations.",
+	}, []string{"code", "method", routeHashLabel})
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithRouteHashLabel()))
+	noop := func(http.ResponseWriter, *http.Request) {}
+	router.HandleFunc("/users/{id}", noop)
+	router.HandleFunc("/orders", noop)
+	for _, target := range []string{"/users/1", "/users/2", "/orders"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	// The hashes are fixed, so that they stay the same across releases.
+	for _, tc := range []struct {
+		path string
+		hash string
+		want uint64
+	}{
+		{"/users/{id}", "31ec5d10", 2},
+		{"/orders", "1bee2c1f", 1},
+	} {
+		if got := routeHash(tc.path); got != tc.hash {
+			t.Errorf("hash of %s is %s, want %s", tc.path, got, tc.hash)
+		}
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{routeHashLabel: tc.hash}); count != tc.want {
+			t.Errorf("got %d observations for %s, want %d", count, tc.path, tc.want)
+		}
+	}
+}
+
+func TestWithRouteHashLabelGroups(t *testing.T) {
+	obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_request_duration_seconds",
+		Help: "Test request durations.",
+	}, []string{"code", "method", routeHashLabel})
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithRouteHashLabel(), WithRouteGroups(map[string]string{"/users": "users"})))
+	router.HandleFunc("/users/{id}", func(http.ResponseWriter, *http.Request) {})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	// The group is hashed, not the template.
+	if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{routeHashLabel: "5e7cc513"}); count != 1 {
+		t.Errorf("got %d observations for the users group, want 1", count)
+	}
+}
@@ -0,0 +1,12 @@
+package prom_mux
+
+// WithRouteNameLabel makes the path label hold the name routes were given
+// with mux.Route.Name, e.g. "getUser", instead of their path template, so
+// that dashboards survive changes of the URL layout. Routes without a name
+// keep their template, and requests without a route are still labeled as
+// unmatched.
+func WithRouteNameLabel() Option {
+	return func(o *options) {
+		o.routeName = true
+	}
+}
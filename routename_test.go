@@ -0,0 +1,44 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRouteNameLabel(t *testing.T) {
+	obs := newDurationVec()
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithRouteNameLabel()))
+	noop := func(http.ResponseWriter, *http.Request) {}
+	router.HandleFunc("/users/{id}", noop).Name("getUser")
+	router.HandleFunc("/orders/{id}", noop)
+	for _, target := range []string{"/users/1", "/users/2", "/orders/1"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	// Without a router there is no route at all.
+	InstrumentHandlerDuration(obs, http.HandlerFunc(noop), WithRouteNameLabel()).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/carts/1", nil))
+
+	for _, tc := range []struct {
+		name string
+		path string
+		want uint64
+	}{
+		{"named route", "getUser", 2},
+		{"unnamed route", "/orders/{id}", 1},
+		{"no route", defaultUnmatchedPath, 1},
+		{"no template of named route", "/users/{id}", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.path}); count != tc.want {
+				t.Errorf("got %d observations with path %q, want %d", count, tc.path, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package prom_mux
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// NewMetricsRouter returns a router that observes the duration of every
+// request served by its routes in a "http_request_duration_seconds"
+// histogram registered with reg, and that serves the metrics gathered by
+// reg on "/metrics". If reg is nil, the prometheus default registry is
+// used.
+//
+// Requests to "/metrics" are not instrumented, so scrapes don't show up in
+// the request metrics.
+func NewMetricsRouter(reg *prometheus.Registry) *mux.Router {
+	var (
+		registerer prometheus.Registerer = reg
+		gatherer   prometheus.Gatherer   = reg
+	)
+	if reg == nil {
+		registerer = prometheus.DefaultRegisterer
+		gatherer = prometheus.DefaultGatherer
+	}
+
+	obs, err := NewRequestDurationHistogram(registerer, prometheus.HistogramOpts{
+		Buckets: prometheus.DefBuckets,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	router := mux.NewRouter()
+	metricsRoute := router.Handle(
+		"/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}),
+	)
+	o := newOptions(nil)
+	router.Use(func(next http.Handler) http.Handler {
+		instrumented := instrumentDuration(obs, next, o)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mux.CurrentRoute(r) == metricsRoute {
+				next.ServeHTTP(w, r)
+				return
+			}
+			instrumented.ServeHTTP(w, r)
+		})
+	})
+	return router
+}
@@ -0,0 +1,44 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestNewMetricsRouter(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	router := NewMetricsRouter(reg)
+	router.HandleFunc("/users/{id}", func(http.ResponseWriter, *http.Request) {})
+	for _, target := range []string{"/users/1", "/users/2", "/metrics"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200", target, rec.Code)
+		}
+		if target == "/metrics" && !strings.Contains(rec.Body.String(), "http_request_duration_seconds") {
+			t.Errorf("/metrics doesn't serve the request durations:\n%s", rec.Body)
+		}
+	}
+
+	obs, err := GetOrRegisterRequestDuration(reg, prometheus.HistogramOpts{Buckets: prometheus.DefBuckets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		path string
+		want uint64
+	}{
+		{"/users/{id}", 2},
+		{"/metrics", 0},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.path}); count != tc.want {
+			t.Errorf("got %d observations with path %q, want %d", count, tc.path, tc.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package prom_mux
+
+import "net/http"
+
+// WithRouteVarLabel adds a label called name holding the value of the route
+// variable of the same name, e.g. "type" for a route like
+// "/{type}/{id}" serving several kinds of entities. Only the allowed values
+// are used as label values; all others are labeled "other", and requests
+// whose route has no such variable, or that weren't routed by a
+// mux.Router, "none".
+func WithRouteVarLabel(name string, allowed ...string) Option {
+	set := newStringSet(allowed)
+	return func(o *options) {
+		o.addLabel(name, func(r *http.Request, _ Delegator) string {
+			v, ok := routeVar(r, name)
+			if !ok {
+				return noneLabelValue
+			}
+			return set.get(v)
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithRouteVarLabel(t *testing.T) {
+	obs := newDurationVec("type")
+	router := mux.NewRouter()
+	router.Use(Middleware(obs, WithRouteVarLabel("type", "users", "orders")))
+	noop := func(http.ResponseWriter, *http.Request) {}
+	router.HandleFunc("/{type}/{id}", noop)
+	router.HandleFunc("/health", noop)
+
+	for _, target := range []string{"/users/1", "/users/2", "/files/3", "/health"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+	for _, tc := range []struct {
+		value string
+		want  uint64
+	}{
+		{"users", 2},
+		{"orders", 0},
+		{otherLabelValue, 1},
+		{noneLabelValue, 1},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"type": tc.value}); count != tc.want {
+			t.Errorf("got %d observations with type %q, want %d", count, tc.value, tc.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package prom_mux
+
+import "net/http"
+
+// WithSkipPaths makes the instrument pass the requests for which skip
+// returns true straight to the wrapped handler, without recording anything
+// or wrapping the ResponseWriter, e.g. health checks that would only skew
+// the latency dashboards.
+func WithSkipPaths(skip func(*http.Request) bool) Option {
+	return func(o *options) {
+		o.skips = append(o.skips, skip)
+	}
+}
+
+// WithSkipRoutes is like WithSkipPaths, skipping the requests whose mux
+// route has one of the given names, as set with mux.Route.Name.
+func WithSkipRoutes(names ...string) Option {
+	set := newStringSet(names)
+	return WithSkipPaths(func(r *http.Request) bool {
+		name, ok := routeName(r)
+		if !ok {
+			return false
+		}
+		_, skip := set[name]
+		return skip
+	})
+}
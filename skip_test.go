@@ -0,0 +1,49 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestSkipRoutes(t *testing.T) {
+	obs := newDurationVec()
+	router := mux.NewRouter()
+	router.Use(Middleware(obs,
+		WithSkipRoutes("metrics"),
+		WithSkipPaths(func(r *http.Request) bool { return r.URL.Path == "/healthz" }),
+	))
+	wrapped := map[string]bool{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		_, wrapped[r.URL.Path] = w.(Delegator)
+	}
+	router.HandleFunc("/metrics", handler).Name("metrics")
+	router.HandleFunc("/healthz", handler)
+	router.HandleFunc("/users/{id}", handler).Name("user")
+	for _, target := range []string{"/metrics", "/healthz", "/users/1"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	for _, tc := range []struct {
+		target string
+		path   string
+		want   uint64
+	}{
+		{"/metrics", "/metrics", 0},
+		{"/healthz", "/healthz", 0},
+		{"/users/1", "/users/{id}", 1},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.path}); count != tc.want {
+			t.Errorf("got %d observations for %s, want %d", count, tc.path, tc.want)
+		}
+		// Skipped requests get the ResponseWriter as is.
+		if wrapped[tc.target] != (tc.want > 0) {
+			t.Errorf("ResponseWriter of %s wrapped: %v, want %v", tc.target, wrapped[tc.target], tc.want > 0)
+		}
+	}
+}
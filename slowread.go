@@ -0,0 +1,64 @@
+package prom_mux
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stallBody counts the reads from a request body that took longer than a
+// threshold.
+type stallBody struct {
+	io.ReadCloser
+
+	now    func() time.Time
+	stall  time.Duration
+	stalls int
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	start := b.now()
+	n, err := b.ReadCloser.Read(p)
+	if b.now().Sub(start) > b.stall {
+		b.stalls++
+	}
+	return n, err
+}
+
+// InstrumentHandlerSlowReads counts with counter the reads of the request
+// body by next that took longer than stall each, which is how slow-loris
+// style clients, trickling their body in to hold connections open, show
+// up. counter is partitioned like the observer of
+// InstrumentHandlerDuration and is only incremented for requests with at
+// least one stalled read.
+//
+// Unlike the total time spent reading the body, this flags clients that
+// keep the connection barely alive even when the body is small. Bear in
+// mind that a read also blocks while the client waits for a 100 Continue.
+func InstrumentHandlerSlowReads(
+	counter *prometheus.CounterVec, stall time.Duration, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerSlowReads", "counter", counter)
+	mustNotBeNil("InstrumentHandlerSlowReads", "handler", next)
+	o := newOptions(opts)
+	counter = o.curryCounter(counter)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		body := &stallBody{ReadCloser: r.Body, now: o.now, stall: stall}
+		r.Body = body
+		d := newDelegator(w, nil)
+		next.ServeHTTP(d, r)
+
+		if body.stalls > 0 {
+			labels := o.labels(r, d)
+			o.add(counter.With(labels), float64(body.stalls), r, d, o.now().Sub(now))
+			releaseLabels(labels)
+		}
+	}
+}
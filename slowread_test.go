@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// trickleReader returns one byte per read, each after advancing clock by
+// the next of delays.
+type trickleReader struct {
+	clock  *prommuxtest.Clock
+	delays []time.Duration
+}
+
+func (r *trickleReader) Read(p []byte) (int, error) {
+	if len(r.delays) == 0 {
+		return 0, io.EOF
+	}
+	r.clock.Advance(r.delays[0])
+	r.delays = r.delays[1:]
+	p[0] = 'x'
+	return 1, nil
+}
+
+func TestInstrumentHandlerSlowReads(t *testing.T) {
+	const ms = time.Millisecond
+	for _, tc := range []struct {
+		name   string
+		delays []time.Duration
+		want   uint64
+	}{
+		{"fast", []time.Duration{ms, ms, ms}, 0},
+		{"at threshold", []time.Duration{100 * ms}, 0},
+		{"one stall", []time.Duration{ms, 2 * time.Second, ms}, 1},
+		{"slow loris", []time.Duration{time.Second, time.Second, time.Second, time.Second}, 4},
+		{"empty", nil, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			cnt := newCounterVec()
+			h := InstrumentHandlerSlowReads(cnt, 100*ms, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(ioutil.Discard, r.Body)
+			}), WithClock(clock.Now))
+			body := &trickleReader{clock: clock, delays: tc.delays}
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", body))
+
+			if count, _ := prommuxtest.CollectObservation(t, cnt, prometheus.Labels{"code": "200"}); count != tc.want {
+				t.Errorf("got %d stalled reads, want %d", count, tc.want)
+			}
+		})
+	}
+}
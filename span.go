@@ -0,0 +1,44 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// SpanEventName is the name of the event added by WithSpanEvent.
+const SpanEventName = "http.server.metrics"
+
+// Span is the part of a tracing span WithSpanEvent needs. It keeps this
+// package free of any tracing dependency; for OpenTelemetry, an adapter
+// converting the attributes to attribute.KeyValue and calling
+// trace.Span.AddEvent is all it takes.
+type Span interface {
+	AddEvent(name string, attributes map[string]interface{})
+}
+
+// WithSpanEvent makes the instrument add an event named SpanEventName to
+// the span spanFromContext returns for the request context, with the
+// status code, duration in seconds and bytes written that were observed.
+// Nothing is added if spanFromContext returns nil.
+func WithSpanEvent(spanFromContext func(context.Context) Span) Option {
+	return func(o *options) {
+		o.spanFromContext = spanFromContext
+	}
+}
+
+func (o *options) addSpanEvent(r *http.Request, d Delegator, elapsed time.Duration) {
+	if o.spanFromContext == nil {
+		return
+	}
+	span := o.spanFromContext(r.Context())
+	if span == nil {
+		return
+	}
+	code := effectiveCode(o.status(r, d))
+	span.AddEvent(SpanEventName, map[string]interface{}{
+		"http.status_code":             code,
+		"http.server.duration":         elapsed.Seconds(),
+		"http.response_content_length": d.Written(),
+	})
+}
@@ -0,0 +1,91 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+type recordingSpan struct {
+	names  []string
+	events []map[string]interface{}
+}
+
+func (s *recordingSpan) AddEvent(name string, attributes map[string]interface{}) {
+	s.names = append(s.names, name)
+	s.events = append(s.events, attributes)
+}
+
+type spanKey struct{}
+
+func TestWithSpanEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		traced bool
+		status int
+		body   string
+		want   map[string]interface{}
+	}{
+		{"traced", true, http.StatusNotFound, "not found", map[string]interface{}{
+			"http.status_code":             404,
+			"http.server.duration":         0.5,
+			"http.response_content_length": int64(9),
+		}},
+		{"implicit status", true, 0, "ok", map[string]interface{}{
+			"http.status_code":             200,
+			"http.server.duration":         0.5,
+			"http.response_content_length": int64(2),
+		}},
+		{"not traced", false, http.StatusOK, "", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(500 * time.Millisecond)
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				w.Write([]byte(tc.body))
+			}), WithClock(clock.Now), WithSpanEvent(func(ctx context.Context) Span {
+				span, _ := ctx.Value(spanKey{}).(*recordingSpan)
+				if span == nil {
+					return nil
+				}
+				return span
+			}))
+			span := &recordingSpan{}
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.traced {
+				r = r.WithContext(context.WithValue(r.Context(), spanKey{}, span))
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if !tc.traced {
+				if len(span.events) != 0 {
+					t.Errorf("got %d events without a span, want none", len(span.events))
+				}
+				return
+			}
+			if len(span.events) != 1 || span.names[0] != SpanEventName {
+				t.Fatalf("got events %q, want one %q", span.names, SpanEventName)
+			}
+			if !reflect.DeepEqual(span.events[0], tc.want) {
+				t.Errorf("got attributes %v, want %v", span.events[0], tc.want)
+			}
+			// The event carries what was observed.
+			code := sanitizeCode(tc.want["http.status_code"].(int))
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": code})
+			if count != 1 || sum != tc.want["http.server.duration"] {
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, tc.want["http.server.duration"])
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package prom_mux
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// WithStatusClassLabel makes the code label hold the class of the status,
+// "1xx" to "5xx", instead of the status itself, trading detail for fewer
+// series on APIs answering with many different codes. Statuses outside of
+// 100 to 599 are labeled "unknown".
+func WithStatusClassLabel() Option {
+	return func(o *options) {
+		o.statusClass = true
+	}
+}
+
+// statusClass returns the class of the status s as set by a handler,
+// counting 0 as 200 like sanitizeCode.
+func statusClass(s int) string {
+	s = effectiveCode(s)
+	if s < 100 || s > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(s/100) + "xx"
+}
+
+// WithExplicitNoResponseCode sets the code label of requests whose handler
+// returned without setting a status or writing anything to label, e.g.
+// "none", instead of "200". net/http does answer those with an empty 200,
+// but a handler doing so is usually a bug worth alerting on.
+func WithExplicitNoResponseCode(label string) Option {
+	return func(o *options) {
+		o.noResponseCode = label
+	}
+}
+
+// invalidCode is the code label used by WithInvalidCodeLabel.
+const invalidCode = "invalid"
+
+// WithInvalidCodeLabel labels requests whose handler set a status outside
+// of 100 to 599, e.g. with WriteHeader(999), as "invalid" instead of with
+// the status itself. Such statuses are handler bugs, which this makes easy
+// to alert on without cluttering dashboards. Not setting a status at all is
+// still counted as 200.
+//
+// net/http panics on WriteHeader with a status below 100 or above 999, and
+// a request whose handler panics is not observed, so with its
+// ResponseWriter this only applies to the statuses from 600 to 999. Lower
+// ones are labeled "invalid" only with ResponseWriters that don't check the
+// status.
+func WithInvalidCodeLabel() Option {
+	return func(o *options) {
+		o.invalidCode = true
+	}
+}
+
+// invalidStatus reports whether status, as returned for d, was explicitly
+// set to a value outside of 100 to 599.
+func invalidStatus(status int, d Delegator) bool {
+	if status == 0 {
+		h, ok := d.(interface{ headerWritten() bool })
+		return ok && h.headerWritten()
+	}
+	return status < 100 || status > 599
+}
+
+func (r *responseWriterDelegator) headerWritten() bool {
+	return r.wroteHeader
+}
+
+// WithStatusOriginLabel adds a "status_origin" label telling how the status
+// was set: "explicit" if the handler called WriteHeader, "implicit" if
+// net/http defaulted to 200 because the handler wrote, flushed or copied
+// the body first, and "none" if the handler did neither. This helps finding
+// handlers, e.g. in a proxy, that are supposed to always set a status.
+func WithStatusOriginLabel() Option {
+	return func(o *options) {
+		o.addLabel("status_origin", func(_ *http.Request, d Delegator) string {
+			s, ok := d.(interface{ statusOrigin() string })
+			if !ok {
+				return noneLabelValue
+			}
+			return s.statusOrigin()
+		})
+	}
+}
+
+func (r *responseWriterDelegator) statusOrigin() string {
+	switch {
+	case !r.wroteHeader:
+		return noneLabelValue
+	case r.implicitHeader:
+		return "implicit"
+	default:
+		return "explicit"
+	}
+}
@@ -0,0 +1,169 @@
+package prom_mux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// uncheckedRecorder is a ResponseRecorder that accepts any status, unlike
+// the one of net/http/httptest and the ResponseWriter of net/http, which
+// panic on statuses below 100 or above 999.
+type uncheckedRecorder struct {
+	*httptest.ResponseRecorder
+	status int
+}
+
+func (r *uncheckedRecorder) WriteHeader(code int) {
+	r.status = code
+}
+
+func TestWithInvalidCodeLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		want   string
+	}{
+		{"none", -1, "200"}, // -1: WriteHeader not called
+		{"ok", 200, "200"},
+		{"highest", 599, "599"},
+		{"zero", 0, invalidCode},
+		{"negative", -200, invalidCode},
+		{"undefined", 600, invalidCode},
+		{"last three digits", 999, invalidCode},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.status != -1 {
+					w.WriteHeader(tc.status)
+				}
+			}), WithInvalidCodeLabel())
+			h.ServeHTTP(&uncheckedRecorder{ResponseRecorder: httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.want}); count != 1 {
+				t.Errorf("got %d observations with code %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+// TestWithInvalidCodeLabelPanic checks that a status net/http rejects
+// leaves nothing observed, as documented.
+func TestWithInvalidCodeLabelPanic(t *testing.T) {
+	obs := newDurationVec()
+	h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(0)
+	}), WithInvalidCodeLabel())
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("WriteHeader(0) did not panic")
+			}
+		}()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+	if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": invalidCode}); count != 0 {
+		t.Errorf("got %d observations with code %q, want 0", count, invalidCode)
+	}
+}
+
+func TestWithStatusOriginLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		code  string
+		want  string
+	}{
+		{"explicit 200", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("body"))
+		}, "200", "explicit"},
+		{"explicit 404", func(w http.ResponseWriter) { w.WriteHeader(http.StatusNotFound) }, "404", "explicit"},
+		{"Write", func(w http.ResponseWriter) { w.Write([]byte("body")) }, "200", "implicit"},
+		{"Flush", func(w http.ResponseWriter) { w.(http.Flusher).Flush() }, "200", "implicit"},
+		{"ReadFrom", func(w http.ResponseWriter) {
+			w.(io.ReaderFrom).ReadFrom(strings.NewReader("body"))
+		}, "200", "implicit"},
+		{"nothing", func(http.ResponseWriter) {}, "200", noneLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("status_origin")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				tc.write(w)
+			}), WithStatusOriginLabel())
+			w := &readerFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+			labels := prometheus.Labels{"code": tc.code, "status_origin": tc.want}
+			if count, _ := prommuxtest.CollectObservation(t, obs, labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, labels)
+			}
+		})
+	}
+}
+
+func TestWithStatusClassLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int // 0: not set
+		hijack bool
+		want   string
+	}{
+		{"switching protocols", http.StatusSwitchingProtocols, false, "1xx"},
+		{"ok", http.StatusOK, false, "2xx"},
+		{"implicit", 0, false, "2xx"},
+		{"no content", http.StatusNoContent, false, "2xx"},
+		{"found", http.StatusFound, false, "3xx"},
+		{"not found", http.StatusNotFound, false, "4xx"},
+		{"unavailable", http.StatusServiceUnavailable, false, "5xx"},
+		{"unknown", 700, false, "unknown"},
+		{"hijacked", 0, true, hijackedCode},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+				if tc.hijack {
+					conn, _, _ := w.(http.Hijacker).Hijack()
+					conn.Close()
+				}
+			}), WithStatusClassLabel())
+			h.ServeHTTP(hijackRecorder{httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.want}); count != 1 {
+				t.Errorf("got %d observations with code %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithExplicitNoResponseCode(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"never writes", func(http.ResponseWriter, *http.Request) {}, "none"},
+		{"explicit ok", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }, "200"},
+		{"implicit ok", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, "ok") }, "200"},
+		{"not found", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNotFound) }, "404"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			h := InstrumentHandlerDuration(obs, tc.handler, WithExplicitNoResponseCode("none"))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": tc.want}); count != 1 {
+				t.Errorf("got %d observations with code %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
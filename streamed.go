@@ -0,0 +1,27 @@
+package prom_mux
+
+import "net/http"
+
+// WithStreamedLabel adds a "streamed" label, "true" if the handler flushed
+// the response at least once and "false" otherwise. Streaming responses,
+// like server-sent events or chunked downloads, typically last as long as
+// the client stays connected; the label keeps them from skewing the
+// durations of buffered responses served by the same route.
+//
+// Only flushes through the http.Flusher of the ResponseWriter are seen,
+// including the ones done by http.ResponseController, which looks for it.
+func WithStreamedLabel() Option {
+	return func(o *options) {
+		o.addLabel("streamed", func(_ *http.Request, d Delegator) string {
+			f, ok := d.(interface{ wasFlushed() bool })
+			if ok && f.wasFlushed() {
+				return "true"
+			}
+			return "false"
+		})
+	}
+}
+
+func (r *responseWriterDelegator) wasFlushed() bool {
+	return r.flushed
+}
@@ -0,0 +1,58 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+// nonFlusher hides the http.Flusher of a ResponseRecorder.
+type nonFlusher struct {
+	http.ResponseWriter
+}
+
+func TestWithStreamedLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		w        http.ResponseWriter
+		events   int
+		streamed string
+		sum      float64
+	}{
+		{"server-sent events", httptest.NewRecorder(), 3, "true", 3},
+		{"buffered", httptest.NewRecorder(), 0, "false", 0.5},
+		{"not flushable", nonFlusher{httptest.NewRecorder()}, 3, "false", 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec("streamed")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.events == 0 {
+					clock.Advance(500 * time.Millisecond)
+					w.Write([]byte("buffered"))
+					return
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				for i := 0; i < tc.events; i++ {
+					clock.Advance(time.Second)
+					fmt.Fprintf(w, "data: %d\n\n", i)
+					if f, ok := w.(http.Flusher); ok {
+						f.Flush()
+					}
+				}
+			}), WithStreamedLabel(), WithClock(clock.Now))
+			h.ServeHTTP(tc.w, httptest.NewRequest("GET", "/events", nil))
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"streamed": tc.streamed})
+			if count != 1 || !approx(sum, tc.sum) {
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, tc.sum)
+			}
+		})
+	}
+}
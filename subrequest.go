@@ -0,0 +1,82 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type subrequestRecorder struct {
+	obs       prometheus.ObserverVec
+	now       func() time.Time
+	pathLabel string
+	path      string
+}
+
+// InstrumentSubrequests lets the handlers wrapped by next report the
+// duration of the sub-requests they make while serving a request (e.g.
+// calls through an in-process client) with ObserveSubrequest or
+// StartSubrequest. The durations are observed with obs, which must be
+// partitioned by "path" (or the name set with WithLabelNames), holding the
+// path label of the originating request, and "target", the name given by
+// the handler. The path is taken
+// when the request enters the instrument, so it has to wrap handlers
+// registered with the router rather than the router itself.
+func InstrumentSubrequests(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentSubrequests", "observer", obs)
+	mustNotBeNil("InstrumentSubrequests", "handler", next)
+	o := newOptions(opts)
+	o.checkLabelNames(obs, o.pathLabelName(), "target")
+	obs = o.curryConst(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &subrequestRecorder{
+			obs:       obs,
+			now:       o.now,
+			pathLabel: o.pathLabelName(),
+			path:      o.pathLabelValue(r),
+		}
+		next.ServeHTTP(w, r.WithContext(
+			context.WithValue(r.Context(), subrequestKey, rec),
+		))
+	}
+}
+
+// ObserveSubrequest records that a sub-request to target, made while
+// serving the request ctx belongs to, took d. It does nothing if the
+// request is not instrumented with InstrumentSubrequests.
+func ObserveSubrequest(ctx context.Context, target string, d time.Duration) {
+	rec, ok := ctx.Value(subrequestKey).(*subrequestRecorder)
+	if !ok {
+		return
+	}
+	rec.obs.With(prometheus.Labels{
+		rec.pathLabel: rec.path,
+		"target":      target,
+	}).Observe(d.Seconds())
+}
+
+// StartSubrequest starts timing a sub-request to target and returns a
+// function to call once it is done, typically deferred:
+//
+//	defer prom_mux.StartSubrequest(r.Context(), "users")()
+//
+// The time is taken with the clock of the InstrumentSubrequests instrument,
+// see WithClock.
+func StartSubrequest(ctx context.Context, target string) func() {
+	now := time.Now
+	if rec, ok := ctx.Value(subrequestKey).(*subrequestRecorder); ok {
+		now = rec.now
+	}
+	start := now()
+	return func() {
+		ObserveSubrequest(ctx, target, now().Sub(start))
+	}
+}
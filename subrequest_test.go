@@ -0,0 +1,54 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentSubrequests(t *testing.T) {
+	clock := prommuxtest.NewClock(time.Unix(0, 0))
+	obs := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_subrequest_duration_seconds",
+		Help: "Test sub-request durations.",
+	}, []string{"path", "target"})
+	router := mux.NewRouter()
+	router.Handle("/users/{id}", InstrumentSubrequests(obs, http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ObserveSubrequest(r.Context(), "db", 30*time.Millisecond)
+			for i := 0; i < 2; i++ {
+				done := StartSubrequest(r.Context(), "cache")
+				clock.Advance(5 * time.Millisecond)
+				done()
+			}
+		},
+	), WithClock(clock.Now)))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	for _, tc := range []struct {
+		target string
+		count  uint64
+		sum    float64
+	}{
+		{"db", 1, 0.03},
+		{"cache", 2, 0.01},
+	} {
+		labels := prometheus.Labels{"path": "/users/{id}", "target": tc.target}
+		count, sum := prommuxtest.CollectObservation(t, obs, labels)
+		if count != tc.count || !approx(sum, tc.sum) {
+			t.Errorf("%s: got %d observations summing to %v, want %d summing to %v",
+				tc.target, count, sum, tc.count, tc.sum)
+		}
+	}
+
+	// Outside of an instrumented request, sub-requests are ignored.
+	ObserveSubrequest(context.Background(), "db", time.Second)
+	StartSubrequest(context.Background(), "db")()
+}
@@ -0,0 +1,41 @@
+package prom_mux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// WithTimeoutHandlerStatus records requests whose context deadline has
+// passed by the time the wrapped handler returns with code 503, the status
+// http.TimeoutHandler sends when it gives up on a request.
+//
+// When an instrument is wrapped by http.TimeoutHandler, the handler keeps
+// running after the timeout and whatever status it sets never reaches the
+// client. If the handler writes anything after the timeout, the Write fails
+// with http.ErrHandlerTimeout and 503 is recorded even without this
+// option. A handler that doesn't write after the timeout would be recorded
+// with a stale status though; this option covers that case, provided the
+// request deadline is the one set by http.TimeoutHandler.
+func WithTimeoutHandlerStatus() Option {
+	return func(o *options) {
+		o.timeoutStatus = true
+	}
+}
+
+// WithTimeoutLabel adds a "timeout" label, "true" if the deadline of the
+// request context, e.g. the one set by http.TimeoutHandler, had passed by
+// the time the handler returned, and "false" otherwise. It sets requests
+// cut short by the server apart from ones that were merely slow. It can be
+// combined with WithContextCancelLabel: a context is either canceled or
+// past its deadline, never both.
+func WithTimeoutLabel() Option {
+	return func(o *options) {
+		o.addLabel("timeout", func(r *http.Request, _ Delegator) string {
+			if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+				return "true"
+			}
+			return "false"
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentBehindTimeoutHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		write  bool
+		opts   []Option
+		labels prometheus.Labels
+	}{
+		{"writes late", true, nil, prometheus.Labels{"code": "503"}},
+		{"stale status", false, nil, prometheus.Labels{"code": "200"}},
+		{"stale status with option", false, []Option{WithTimeoutHandlerStatus()},
+			prometheus.Labels{"code": "503"}},
+		{"timeout label", false, []Option{WithTimeoutHandlerStatus(), WithTimeoutLabel()},
+			prometheus.Labels{"code": "503", "timeout": "true"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var extra []string
+			if _, ok := tc.labels["timeout"]; ok {
+				extra = append(extra, "timeout")
+			}
+			obs := newDurationVec(extra...)
+			late, done := make(chan struct{}), make(chan struct{})
+			instrumented := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Only write once TimeoutHandler answered the client.
+				<-late
+				w.WriteHeader(http.StatusOK)
+				if tc.write {
+					w.Write([]byte("too late"))
+				}
+			}), tc.opts...)
+			// TimeoutHandler returns without waiting for the handler.
+			h := http.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(done)
+				instrumented.ServeHTTP(w, r)
+			}), time.Millisecond, "")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+			if rec.Code != http.StatusServiceUnavailable {
+				t.Errorf("client got status %d, want 503", rec.Code)
+			}
+			close(late)
+			<-done
+			if count, _ := prommuxtest.CollectObservation(t, obs, tc.labels); count != 1 {
+				t.Errorf("got %d observations with labels %v, want 1", count, tc.labels)
+			}
+		})
+	}
+}
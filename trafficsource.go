@@ -0,0 +1,73 @@
+package prom_mux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Values of the "traffic_source" label.
+const (
+	trafficInternal = "internal"
+	trafficExternal = "external"
+	trafficUnknown  = "unknown"
+)
+
+// WithTrafficSourceLabel adds a "traffic_source" label telling whether the
+// client address is in one of internalCIDRs ("internal") or not
+// ("external"), e.g. to separate health checkers and other internal callers
+// from real users. Addresses that can't be parsed are labeled "unknown".
+// The client address is taken from r.RemoteAddr, unless WithClientIPHeader
+// is used. It panics if one of internalCIDRs is invalid.
+func WithTrafficSourceLabel(internalCIDRs []string) Option {
+	nets := make([]*net.IPNet, 0, len(internalCIDRs))
+	for _, cidr := range internalCIDRs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("invalid internal CIDR %q: %v", cidr, err))
+		}
+		nets = append(nets, n)
+	}
+	return func(o *options) {
+		o.addLabel("traffic_source", func(r *http.Request, _ Delegator) string {
+			ip := o.clientIP(r)
+			if ip == nil {
+				return trafficUnknown
+			}
+			for _, n := range nets {
+				if n.Contains(ip) {
+					return trafficInternal
+				}
+			}
+			return trafficExternal
+		})
+	}
+}
+
+// WithClientIPHeader makes labels derived from the client address use the
+// last address in the given header, e.g. "X-Forwarded-For", instead of
+// r.RemoteAddr. The last address is the one added by the proxy closest to
+// the server; earlier ones are under the client's control. Only use this
+// when every request goes through a proxy setting the header. Requests
+// without the header fall back to r.RemoteAddr.
+func WithClientIPHeader(name string) Option {
+	return func(o *options) {
+		o.clientIPHeader = name
+	}
+}
+
+// clientIP returns the client address of r, or nil if it can't be parsed.
+func (o *options) clientIP(r *http.Request) net.IP {
+	if o.clientIPHeader != "" {
+		if values := r.Header.Values(o.clientIPHeader); len(values) > 0 {
+			addrs := strings.Split(values[len(values)-1], ",")
+			return net.ParseIP(strings.TrimSpace(addrs[len(addrs)-1]))
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
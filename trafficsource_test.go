@@ -0,0 +1,61 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithTrafficSourceLabel(t *testing.T) {
+	internal := []string{"10.0.0.0/8", "fd00::/8"}
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		header     bool
+		want       string
+	}{
+		{"internal", "10.1.2.3:1234", nil, false, trafficInternal},
+		{"internal IPv6", "[fd00::1]:1234", nil, false, trafficInternal},
+		{"external", "203.0.113.7:1234", nil, false, trafficExternal},
+		{"without port", "10.1.2.3", nil, false, trafficInternal},
+		{"malformed", "not-an-ip", nil, false, trafficUnknown},
+		{"forwarded internal", "203.0.113.7:1234", []string{"198.51.100.1, 10.1.2.3"}, true, trafficInternal},
+		{"forwarded external", "10.1.2.3:1234", []string{"10.9.9.9", "198.51.100.1"}, true, trafficExternal},
+		{"forwarded malformed", "10.1.2.3:1234", []string{"garbage"}, true, trafficUnknown},
+		{"forwarded missing", "10.1.2.3:1234", nil, true, trafficInternal},
+		{"forwarded ignored", "203.0.113.7:1234", []string{"10.1.2.3"}, false, trafficExternal},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("traffic_source")
+			opts := []Option{WithTrafficSourceLabel(internal)}
+			if tc.header {
+				opts = append(opts, WithClientIPHeader("X-Forwarded-For"))
+			}
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), opts...)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"traffic_source": tc.want}); count != 1 {
+				t.Errorf("got %d observations for %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
+
+func TestWithTrafficSourceLabelPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("invalid CIDR did not panic")
+		}
+	}()
+	WithTrafficSourceLabel([]string{"10.0.0.0/33"})
+}
@@ -0,0 +1,30 @@
+package prom_mux
+
+import "net/http"
+
+// WithTrailerLabel adds a label called name holding the value of the
+// response trailer called trailer, for handlers that only know how to
+// categorize a request, e.g. as "hit", "partial" or "error", once the body
+// has been written. Only the allowed values are used as label values;
+// responses without the trailer are labeled "none" and all others "other".
+//
+// The trailer is read from the response header after the handler returned,
+// so it may be either declared in the "Trailer" header or set with the
+// http.TrailerPrefix.
+func WithTrailerLabel(name, trailer string, allowed ...string) Option {
+	trailer = http.CanonicalHeaderKey(trailer)
+	set := newStringSet(allowed)
+	return func(o *options) {
+		o.addLabel(name, func(_ *http.Request, d Delegator) string {
+			h := d.Header()
+			v := h.Get(trailer)
+			if v == "" {
+				v = h.Get(http.TrailerPrefix + trailer)
+			}
+			if v == "" {
+				return noneLabelValue
+			}
+			return set.get(v)
+		})
+	}
+}
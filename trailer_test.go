@@ -0,0 +1,49 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithTrailerLabel(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		want  string
+	}{
+		{"declared", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", "X-Result")
+			w.Write([]byte("body"))
+			w.Header().Set("X-Result", "hit")
+		}, "hit"},
+		{"prefixed", func(w http.ResponseWriter) {
+			w.Write([]byte("body"))
+			w.Header().Set(http.TrailerPrefix+"X-Result", "partial")
+		}, "partial"},
+		{"not allowed", func(w http.ResponseWriter) {
+			w.Header().Set("Trailer", "X-Result")
+			w.Write([]byte("body"))
+			w.Header().Set("X-Result", "unexpected")
+		}, otherLabelValue},
+		{"absent", func(w http.ResponseWriter) {
+			w.Write([]byte("body"))
+		}, noneLabelValue},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec("result")
+			h := InstrumentHandlerDuration(obs, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				tc.write(w)
+			}), WithTrailerLabel("result", "x-result", "hit", "partial", "error"))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"result": tc.want}); count != 1 {
+				t.Errorf("got %d observations for %q, want 1", count, tc.want)
+			}
+		})
+	}
+}
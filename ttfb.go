@@ -0,0 +1,47 @@
+package prom_mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerTimeToWriteHeader observes with obs the time from
+// entering the handler until the response header is written, explicitly or
+// by the first Write, Flush or ReadFrom. This is the server side of the time
+// to first byte and tells how responsive streaming endpoints are, no matter
+// how long the body takes to complete. obs is partitioned like for
+// InstrumentHandlerDuration. Responses that never write anything are not
+// observed.
+//
+// Combined with WithRouteGroups, it yields one series per functional area
+// rather than per route.
+func InstrumentHandlerTimeToWriteHeader(
+	obs prometheus.ObserverVec, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerTimeToWriteHeader", "observer", obs)
+	mustNotBeNil("InstrumentHandlerTimeToWriteHeader", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		var firstWrite time.Time
+		d := newDelegator(w, func(int) {
+			firstWrite = o.now()
+		})
+		next.ServeHTTP(d, r)
+
+		if firstWrite.IsZero() {
+			return
+		}
+		elapsed := firstWrite.Sub(now)
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), elapsed.Seconds(), r, d, elapsed)
+		releaseLabels(labels)
+	}
+}
@@ -0,0 +1,78 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerTimeToWriteHeader(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		write func(http.ResponseWriter)
+		want  float64 // 0: not observed
+	}{
+		{"WriteHeader", func(w http.ResponseWriter) { w.WriteHeader(http.StatusOK) }, 0.1},
+		{"Write", func(w http.ResponseWriter) { w.Write([]byte("x")) }, 0.1},
+		{"Flush", func(w http.ResponseWriter) { w.(http.Flusher).Flush() }, 0.1},
+		{"nothing", func(http.ResponseWriter) {}, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec()
+			h := InstrumentHandlerTimeToWriteHeader(obs, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(100 * time.Millisecond)
+				tc.write(w)
+				// The rest of the body doesn't count.
+				clock.Advance(time.Second)
+			}), WithClock(clock.Now))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"code": "200"})
+			switch {
+			case tc.want == 0 && count != 0:
+				t.Errorf("got %d observations, want none", count)
+			case tc.want != 0 && (count != 1 || !approx(sum, tc.want)):
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstrumentHandlerTimeToWriteHeaderRouteGroups(t *testing.T) {
+	obs := newDurationVec()
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return InstrumentHandlerTimeToWriteHeader(obs, next, WithRouteGroups(map[string]string{
+			"/api/users":  "users",
+			"/api/orders": "orders",
+		}))
+	})
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	router.HandleFunc("/api/users/{id}", ok)
+	router.HandleFunc("/api/users/{id}/avatar", ok)
+	router.HandleFunc("/api/orders", ok)
+	router.HandleFunc("/health", ok)
+	for _, target := range []string{"/api/users/1", "/api/users/1/avatar", "/api/orders", "/health"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	for _, tc := range []struct {
+		group string
+		want  uint64
+	}{
+		{"users", 2},
+		{"orders", 1},
+		{otherLabelValue, 1},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.group}); count != tc.want {
+			t.Errorf("got %d observations for group %q, want %d", count, tc.group, tc.want)
+		}
+	}
+}
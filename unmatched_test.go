@@ -0,0 +1,50 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestUnmatchedPath(t *testing.T) {
+	noop := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	targets := []string{"/a", "/b?q=1", "/c/d/e", "/f%20g"}
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		// route serves the targets through a router whose route has no
+		// path template, rather than without any route.
+		route bool
+		want  string
+	}{
+		{"no route", nil, false, defaultUnmatchedPath},
+		{"no path template", nil, true, defaultUnmatchedPath},
+		{"custom no route", []Option{WithUnmatchedPath("unknown")}, false, "unknown"},
+		{"custom no path template", []Option{WithUnmatchedPath("unknown")}, true, "unknown"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs := newDurationVec()
+			var h http.Handler = InstrumentHandlerDuration(obs, noop, tc.opts...)
+			if tc.route {
+				router := mux.NewRouter()
+				router.Use(Middleware(obs, tc.opts...))
+				router.MatcherFunc(func(*http.Request, *mux.RouteMatch) bool { return true }).Handler(noop)
+				h = router
+			}
+			for _, target := range targets {
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+			}
+
+			// All the URLs share a single series.
+			count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"path": tc.want})
+			if count != uint64(len(targets)) {
+				t.Errorf("got %d observations for %q, want %d", count, tc.want, len(targets))
+			}
+		})
+	}
+}
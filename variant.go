@@ -0,0 +1,37 @@
+package prom_mux
+
+import (
+	"context"
+	"net/http"
+)
+
+// ContextWithVariant returns a copy of ctx carrying the handler variant
+// (e.g. "control" or "canary") chosen by the routing logic for the request.
+func ContextWithVariant(ctx context.Context, variant string) context.Context {
+	return context.WithValue(ctx, variantKey, variant)
+}
+
+// VariantFromContext returns the variant stored in ctx, if any.
+func VariantFromContext(ctx context.Context) (string, bool) {
+	v, ok := ctx.Value(variantKey).(string)
+	return v, ok
+}
+
+// WithVariantLabel adds a "variant" label holding the variant stored in the
+// request context with ContextWithVariant. Only the given variants are used
+// as label values; requests with any other value are labeled "other", and
+// those without a variant "none". The variant has to be in the context of
+// the request the instrument is called with, so the routing logic picking
+// it must run before the instrument.
+func WithVariantLabel(variants ...string) Option {
+	allowed := newStringSet(variants)
+	return func(o *options) {
+		o.addLabel("variant", func(r *http.Request, _ Delegator) string {
+			v, ok := VariantFromContext(r.Context())
+			if !ok {
+				return noneLabelValue
+			}
+			return allowed.get(v)
+		})
+	}
+}
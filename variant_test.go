@@ -0,0 +1,42 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestWithVariantLabel(t *testing.T) {
+	obs := newDurationVec("variant")
+	instrumented := InstrumentHandlerDuration(obs, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}),
+		WithVariantLabel("control", "canary"))
+	// The routing logic picks the variant before the instrument runs.
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get("X-Variant"); v != "" {
+			r = r.WithContext(ContextWithVariant(r.Context(), v))
+		}
+		instrumented.ServeHTTP(w, r)
+	})
+	for _, variant := range []string{"canary", "control", "control", "experiment", ""} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Variant", variant)
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	for _, tc := range []struct {
+		variant string
+		want    uint64
+	}{
+		{"canary", 1},
+		{"control", 2},
+		{otherLabelValue, 1},
+		{noneLabelValue, 1},
+	} {
+		if count, _ := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"variant": tc.variant}); count != tc.want {
+			t.Errorf("got %d observations of variant %q, want %d", count, tc.variant, tc.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package prom_mux
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InstrumentHandlerConcurrencyWeightedDuration observes with obs the
+// duration of every request served by next, in seconds, multiplied by the
+// number of requests in flight in t when it completes, itself included. obs
+// is partitioned like for InstrumentHandlerDuration.
+//
+// The product can be read as the cost of a request in "request-seconds":
+// a request that is slow while the server is busy weighs much more than
+// one equally slow on an idle server, so the high buckets point at the
+// routes that hurt most under load. It is not a latency and its quantiles
+// shouldn't be read as such.
+//
+// The requests are tracked in t by this instrument, so t also exposes the
+// age of the oldest one. Don't additionally wrap the same handlers with
+// InstrumentHandlerInFlightAge on the same tracker, or they would be
+// counted twice.
+func InstrumentHandlerConcurrencyWeightedDuration(
+	obs prometheus.ObserverVec, t *InFlightTracker, next http.Handler, opts ...Option,
+) http.HandlerFunc {
+	mustNotBeNil("InstrumentHandlerConcurrencyWeightedDuration", "observer", obs)
+	mustNotBeNil("InstrumentHandlerConcurrencyWeightedDuration", "tracker", t)
+	mustNotBeNil("InstrumentHandlerConcurrencyWeightedDuration", "handler", next)
+	o := newOptions(opts)
+	obs = o.curry(obs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if o.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		now, r := o.start(r)
+		id := t.start(time.Now())
+		defer t.done(id)
+		d, rwd := acquireDelegator(w)
+		next.ServeHTTP(d, r)
+
+		elapsed := o.now().Sub(now)
+		weighted := elapsed.Seconds() * float64(t.inFlight())
+		labels := o.labels(r, d)
+		o.observe(obs.With(labels), weighted, r, d, elapsed)
+		releaseLabels(labels)
+		releaseDelegator(rwd)
+	}
+}
@@ -0,0 +1,60 @@
+package prom_mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/olomix/prom-mux/prommuxtest"
+)
+
+func TestInstrumentHandlerConcurrencyWeightedDuration(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		busy int
+		want float64
+	}{
+		{"idle", 0, 1},
+		{"busy", 3, 4},
+		{"very busy", 9, 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := prommuxtest.NewClock(time.Unix(0, 0))
+			obs := newDurationVec()
+			tracker := NewInFlightTracker(prometheus.GaugeOpts{
+				Name: "test_oldest_request_age_seconds",
+				Help: "Test age of the oldest request in flight.",
+			})
+			b := prommuxtest.NewBarrier()
+			// GET requests keep the server busy while a POST request
+			// takes a second.
+			h := InstrumentHandlerConcurrencyWeightedDuration(obs, tracker, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					clock.Advance(time.Second)
+					return
+				}
+				b.Wait()
+			}), WithClock(clock.Now))
+
+			done := make(chan *httptest.ResponseRecorder, tc.busy)
+			for i := 0; i < tc.busy; i++ {
+				res := prommuxtest.Serve(h, httptest.NewRequest("GET", "/", nil))
+				go func() { done <- <-res }()
+			}
+			b.WaitHeld(tc.busy)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+			b.ReleaseAll()
+			for i := 0; i < tc.busy; i++ {
+				<-done
+			}
+
+			count, sum := prommuxtest.CollectObservation(t, obs, prometheus.Labels{"method": "post"})
+			if count != 1 || !approx(sum, tc.want) {
+				t.Errorf("got %d observations summing to %v, want 1 of %v", count, sum, tc.want)
+			}
+		})
+	}
+}